@@ -0,0 +1,88 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var ErrNoCase = errors.New("no case matched")
+
+type Predicate = func(ctx context.Context, data *WorkData) bool
+
+// If runs then when the predicate holds and otherwise when it does not,
+// either may be nil to do nothing.
+func If(predicate Predicate, then Event, otherwise Event) Event {
+	return func(ctx context.Context, data *WorkData) error {
+		if predicate(ctx, data) {
+			data.Logger.WithField("branch", "then").Debug("branch taken")
+			if then == nil {
+				return nil
+			}
+			return then(ctx, data)
+		}
+
+		data.Logger.WithField("branch", "else").Debug("branch taken")
+		if otherwise == nil {
+			return nil
+		}
+		return otherwise(ctx, data)
+	}
+}
+
+// Switch runs the case selected by key, or fallback when no case matches.
+// Without a fallback an unmatched key fails with ErrNoCase, a nil case does
+// nothing.
+func Switch[K comparable](key func(ctx context.Context, data *WorkData) K, cases map[K]Event, fallback Event) Event {
+	return func(ctx context.Context, data *WorkData) error {
+		k := key(ctx, data)
+		data.Logger.WithField("branch", k).Debug("branch taken")
+		if f, ok := cases[k]; ok {
+			// like a nil branch of If, a nil case does nothing.
+			if f == nil {
+				return nil
+			}
+			return f(ctx, data)
+		}
+		if fallback != nil {
+			return fallback(ctx, data)
+		}
+		return fmt.Errorf("switch on %v: %w", k, ErrNoCase)
+	}
+}
+
+func Has(key string) Predicate {
+	return func(ctx context.Context, data *WorkData) bool {
+		_, ok := data.Get(key)
+		return ok
+	}
+}
+
+func Equals(key string, value interface{}) Predicate {
+	return func(ctx context.Context, data *WorkData) bool {
+		v, ok := data.Get(key)
+		if !ok {
+			return false
+		}
+		// slices and maps cannot be compared with ==.
+		if t := reflect.TypeOf(v); t != nil && !t.Comparable() {
+			return reflect.DeepEqual(v, value)
+		}
+		return v == value
+	}
+}
+
+func Not(predicate Predicate) Predicate {
+	return func(ctx context.Context, data *WorkData) bool {
+		return !predicate(ctx, data)
+	}
+}
+
+// ValueOf reads a typed key for Switch, the zero value when it is missing.
+func ValueOf[T comparable](key *Key[T]) func(ctx context.Context, data *WorkData) T {
+	return func(ctx context.Context, data *WorkData) T {
+		value, _ := key.Get(data)
+		return value
+	}
+}
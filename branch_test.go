@@ -0,0 +1,120 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Branch", func() {
+	paymentType := workflow.NewKey[string]("payment-type")
+
+	var ran, compensated []string
+
+	BeforeEach(func() {
+		ran = []string{}
+		compensated = []string{}
+	})
+
+	record := func(name string) workflow.Event {
+		return func(ctx context.Context, data *workflow.WorkData) error {
+			ran = append(ran, name)
+			return nil
+		}
+	}
+	step := func(name string) workflow.Step {
+		return workflow.Step{
+			Name:  name,
+			Event: record(name),
+			Compensate: func(ctx context.Context, data *workflow.WorkData) error {
+				compensated = append(compensated, name)
+				return nil
+			},
+		}
+	}
+	payment := workflow.Switch(workflow.ValueOf(paymentType), map[string]workflow.Event{
+		"card":   workflow.Sequence(step("authorize"), step("capture")),
+		"wallet": workflow.Sequence(step("debit")),
+	}, nil)
+
+	It("takes the then branch", func() {
+		_, err := workflow.StartWorkFlow(
+			workflow.If(workflow.Has("vip"), record("then"), record("else")),
+			workflow.WithBegin(func(ctx context.Context, data *workflow.WorkData) error {
+				data.Set("vip", true)
+				return nil
+			}),
+		)
+		Expect(err).Should(BeNil())
+		Expect(ran).To(Equal([]string{"then"}))
+	})
+
+	It("takes the else branch", func() {
+		_, err := workflow.StartWorkFlow(workflow.If(workflow.Equals("vip", true), record("then"), record("else")))
+		Expect(err).Should(BeNil())
+		Expect(ran).To(Equal([]string{"else"}))
+
+		_, err = workflow.StartWorkFlow(workflow.If(workflow.Not(workflow.Has("vip")), record("not"), nil))
+		Expect(err).Should(BeNil())
+		Expect(ran).To(Equal([]string{"else", "not"}))
+
+		_, err = workflow.StartWorkFlow(workflow.If(workflow.Not(workflow.Has("vip")), nil, record("unless")))
+		Expect(err).Should(BeNil())
+		Expect(ran).To(Equal([]string{"else", "not"}))
+	})
+
+	It("routes by key and compensates only the taken branch", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			paymentType.Set(data, "card")
+			if err := payment(ctx, data); err != nil {
+				return err
+			}
+			return errors.New("ship")
+		})
+		Expect(err).ShouldNot(BeNil())
+		Expect(ran).To(Equal([]string{"authorize", "capture"}))
+		Expect(compensated).To(Equal([]string{"capture", "authorize"}))
+	})
+
+	It("fails when no case matches", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			paymentType.Set(data, "cash")
+			return payment(ctx, data)
+		})
+		Expect(errors.Is(err, workflow.ErrNoCase)).To(BeTrue())
+	})
+
+	It("runs the fallback when no case matches", func() {
+		_, err := workflow.StartWorkFlow(workflow.Switch(workflow.ValueOf(paymentType), map[string]workflow.Event{
+			"card": record("card"),
+		}, record("fallback")))
+		Expect(err).Should(BeNil())
+		Expect(ran).To(Equal([]string{"fallback"}))
+	})
+
+	It("does nothing for a nil case", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			paymentType.Set(data, "free")
+			return workflow.Switch(workflow.ValueOf(paymentType), map[string]workflow.Event{
+				"free": nil,
+			}, record("fallback"))(ctx, data)
+		})
+		Expect(err).Should(BeNil())
+		Expect(ran).To(BeEmpty())
+	})
+
+	It("compares slices and maps by value", func() {
+		_, err := workflow.StartWorkFlow(
+			workflow.If(workflow.Equals("tags", []string{"vip"}), record("then"), record("else")),
+			workflow.WithBegin(func(ctx context.Context, data *workflow.WorkData) error {
+				data.Set("tags", []string{"vip"})
+				return nil
+			}),
+		)
+		Expect(err).Should(BeNil())
+		Expect(ran).To(Equal([]string{"then"}))
+	})
+})
@@ -0,0 +1,84 @@
+package workflow_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Concurrency", func() {
+	const workers = 16
+
+	It("aborts from concurrent workers", func() {
+		rolledBack := false
+		data, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				wg := sync.WaitGroup{}
+				for i := 0; i < workers; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						key := fmt.Sprintf("worker-%d", i)
+						data.Set(key, i)
+						data.Get(key)
+						data.IsAborted()
+						if i%4 == 0 {
+							data.AbortWithReason(fmt.Errorf("worker %d", i))
+						}
+					}(i)
+				}
+				wg.Wait()
+				return nil
+			},
+			workflow.WithRollback(func(ctx context.Context, data *workflow.WorkData) error {
+				rolledBack = true
+				return nil
+			}),
+		)
+		Expect(err).Should(BeNil())
+		Expect(rolledBack).To(BeTrue())
+		Expect(data.IsAborted()).To(BeTrue())
+		Expect(data.AbortReason()).ShouldNot(BeNil())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeAborted))
+		for i := 0; i < workers; i++ {
+			Expect(data.MustGet(fmt.Sprintf("worker-%d", i))).To(Equal(i))
+		}
+	})
+
+	It("notifies workers through Done", func() {
+		stopped := make(chan int, workers)
+		data, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				wg := sync.WaitGroup{}
+				for i := 0; i < workers; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						<-data.Done()
+						stopped <- i
+					}(i)
+				}
+				data.Abort()
+				wg.Wait()
+				return nil
+			},
+		)
+		Expect(err).Should(BeNil())
+		Expect(stopped).To(HaveLen(workers))
+		Expect(data.Done()).To(BeClosed())
+	})
+
+	It("keeps Done open for a committed workflow", func() {
+		data, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				return nil
+			},
+		)
+		Expect(err).Should(BeNil())
+		Expect(data.Done()).NotTo(BeClosed())
+	})
+})
@@ -0,0 +1,51 @@
+package workflow
+
+import (
+	"context"
+	"time"
+)
+
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) {
+	return
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// detach keeps the values of ctx but drops its cancellation, so that
+// compensation still completes after the caller's context is done.
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (d *WorkData) phaseContext(ctx context.Context, phase Phase) (context.Context, context.CancelFunc) {
+	if timeout, ok := d.phaseTimeouts[phase]; ok {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+func WithTimeout(timeout time.Duration) Options {
+	return applyFunc(func(data *WorkData) {
+		data.timeout = timeout
+	})
+}
+
+func WithPhaseTimeout(phase Phase, timeout time.Duration) Options {
+	return applyFunc(func(data *WorkData) {
+		data.phaseTimeouts[phase] = timeout
+	})
+}
@@ -0,0 +1,98 @@
+package workflow_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Context", func() {
+	var ran []string
+
+	BeforeEach(func() {
+		ran = []string{}
+	})
+
+	record := func(name string) workflow.Event {
+		return func(ctx context.Context, data *workflow.WorkData) error {
+			ran = append(ran, name)
+			return nil
+		}
+	}
+
+	It("aborts and rolls back when the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		var rollbackErr error
+		data, err := workflow.StartWorkFlowContext(
+			ctx,
+			func(ctx context.Context, data *workflow.WorkData) error {
+				cancel()
+				return nil
+			},
+			workflow.WithCommit(record("commit")),
+			workflow.WithRollback(func(ctx context.Context, data *workflow.WorkData) error {
+				rollbackErr = ctx.Err()
+				return record("rollback")(ctx, data)
+			}),
+		)
+		Expect(err).Should(BeNil())
+		Expect(ran).To(Equal([]string{"rollback"}))
+		Expect(rollbackErr).Should(BeNil())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeAborted))
+		Expect(data.AbortReason()).To(Equal(context.Canceled))
+	})
+
+	It("stops the phase between handlers when the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		data, err := workflow.StartWorkFlowContext(
+			ctx,
+			record("work"),
+			workflow.WithCommit(func(ctx context.Context, data *workflow.WorkData) error {
+				cancel()
+				return nil
+			}),
+			workflow.WithCommit(record("commit")),
+			workflow.WithRollback(record("rollback")),
+		)
+		Expect(err).Should(BeNil())
+		Expect(ran).To(Equal([]string{"work", "rollback"}))
+		Expect(data.IsAborted()).To(BeTrue())
+	})
+
+	It("applies the workflow timeout", func() {
+		data, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				<-ctx.Done()
+				return nil
+			},
+			workflow.WithTimeout(10*time.Millisecond),
+			workflow.WithRollback(record("rollback")),
+		)
+		Expect(err).Should(BeNil())
+		Expect(ran).To(Equal([]string{"rollback"}))
+		Expect(data.AbortReason()).To(Equal(context.DeadlineExceeded))
+	})
+
+	It("applies a phase timeout", func() {
+		var workDeadline bool
+		data, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				_, workDeadline = ctx.Deadline()
+				return nil
+			},
+			workflow.WithPhaseTimeout(workflow.PhaseCommit, 10*time.Millisecond),
+			workflow.WithCommit(func(ctx context.Context, data *workflow.WorkData) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}),
+			workflow.WithRollback(record("rollback")),
+		)
+		Expect(err).Should(MatchError(context.DeadlineExceeded))
+		Expect(workDeadline).To(BeFalse())
+		Expect(ran).To(Equal([]string{"rollback"}))
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRolledBack))
+	})
+})
@@ -0,0 +1,88 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Define", func() {
+	It("rejects an invalid configuration", func() {
+		_, err := workflow.Define("invalid", workflow.WithCommit(nil))
+		Expect(err).Should(MatchError(ContainSubstring(`define workflow "invalid"`)))
+		Expect(func() { workflow.MustDefine("invalid", workflow.WithBegin(nil)) }).To(Panic())
+	})
+
+	It("runs a definition many times concurrently", func() {
+		var commits, rollbacks int32
+		w := workflow.MustDefine(
+			"order",
+			workflow.WithBegin(func(ctx context.Context, data *workflow.WorkData) error {
+				data.Set("begin", true)
+				return nil
+			}),
+			workflow.WithCommit(func(ctx context.Context, data *workflow.WorkData) error {
+				atomic.AddInt32(&commits, 1)
+				return nil
+			}),
+			workflow.WithRollback(func(ctx context.Context, data *workflow.WorkData) error {
+				atomic.AddInt32(&rollbacks, 1)
+				return nil
+			}),
+		)
+		Expect(w.Name()).To(Equal("order"))
+
+		const runs = 50
+		wg := sync.WaitGroup{}
+		for i := 0; i < runs; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				data, err := w.Run(context.Background(), func(ctx context.Context, data *workflow.WorkData) error {
+					if _, ok := data.Get("run"); ok {
+						return errors.New("shared work data")
+					}
+					data.Set("run", i)
+					if i%2 == 1 {
+						return errors.New("odd")
+					}
+					return nil
+				})
+				Expect(data.Name()).To(Equal("order"))
+				Expect(data.MustGet("run")).To(Equal(i))
+				if i%2 == 1 {
+					Expect(err).Should(MatchError(ContainSubstring("odd")))
+				} else {
+					Expect(err).Should(BeNil())
+				}
+			}(i)
+		}
+		wg.Wait()
+		Expect(atomic.LoadInt32(&commits)).To(BeEquivalentTo(runs / 2))
+		Expect(atomic.LoadInt32(&rollbacks)).To(BeEquivalentTo(runs / 2))
+	})
+
+	It("does not leak handlers added to a run into the definition", func() {
+		w := workflow.MustDefine("leak", workflow.WithCommit(func(ctx context.Context, data *workflow.WorkData) error {
+			return nil
+		}))
+		data, err := w.Run(context.Background(), func(ctx context.Context, data *workflow.WorkData) error {
+			return nil
+		})
+		Expect(err).Should(BeNil())
+		Expect(data.Progress(workflow.PhaseCommit)).To(Equal(1))
+
+		data, err = w.Run(context.Background(), func(ctx context.Context, data *workflow.WorkData) error {
+			return nil
+		})
+		Expect(err).Should(BeNil())
+		Expect(data.Progress(workflow.PhaseCommit)).To(Equal(1))
+	})
+})
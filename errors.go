@@ -0,0 +1,97 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// HandlerError is returned by a phase when one of its handlers fails.
+type HandlerError struct {
+	Phase   Phase
+	Index   int
+	Handler string
+	Err     error
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("%v handler #%d (%s): %v", e.Phase, e.Index, e.Handler, e.Err)
+}
+
+func (e *HandlerError) Unwrap() error {
+	return e.Err
+}
+
+// WorkflowError describes why a workflow failed, keeping the original cause
+// together with every error raised while rolling back or finishing.
+type WorkflowError struct {
+	Phase          Phase
+	Index          int
+	Handler        string
+	Cause          error
+	RollbackErrors []error
+	FinishErrors   []error
+}
+
+func (e *WorkflowError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "workflow failed in %v phase", e.Phase)
+	if e.Handler != "" {
+		fmt.Fprintf(&b, " at handler #%d (%s)", e.Index, e.Handler)
+	}
+	if e.Cause != nil {
+		fmt.Fprintf(&b, ": %v", e.Cause)
+	}
+	if len(e.RollbackErrors) > 0 {
+		fmt.Fprintf(&b, "; rollback errors: %v", joinErrors(e.RollbackErrors))
+	}
+	if len(e.FinishErrors) > 0 {
+		fmt.Fprintf(&b, "; finish errors: %v", joinErrors(e.FinishErrors))
+	}
+	return b.String()
+}
+
+// Unwrap returns the cause followed by the rollback and finish errors, so
+// errors.Is and errors.As match any of them.
+func (e *WorkflowError) Unwrap() []error {
+	errs := make([]error, 0, 1+len(e.RollbackErrors)+len(e.FinishErrors))
+	if e.Cause != nil {
+		errs = append(errs, e.Cause)
+	}
+	errs = append(errs, e.RollbackErrors...)
+	return append(errs, e.FinishErrors...)
+}
+
+func newWorkflowError(phase Phase, err error) *WorkflowError {
+	werr := &WorkflowError{
+		Phase: phase,
+		Index: -1,
+		Cause: err,
+	}
+
+	var herr *HandlerError
+	if errors.As(err, &herr) {
+		werr.Phase = herr.Phase
+		werr.Index = herr.Index
+		werr.Handler = herr.Handler
+		werr.Cause = herr.Err
+	}
+	return werr
+}
+
+func handlerName(f Event) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return "unknown"
+}
+
+func joinErrors(errs []error) string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return "[" + strings.Join(msgs, "; ") + "]"
+}
@@ -0,0 +1,140 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WorkflowError", func() {
+	errCommit := errors.New("commit")
+	errRollback := errors.New("rollback")
+
+	nop := func(ctx context.Context, data *workflow.WorkData) error {
+		return nil
+	}
+	fail := func(err error) workflow.Event {
+		return func(ctx context.Context, data *workflow.WorkData) error {
+			return err
+		}
+	}
+
+	It("reports the failing phase and handler", func() {
+		_, err := workflow.StartWorkFlow(
+			nop,
+			workflow.WithCommit(nop),
+			workflow.WithCommit(fail(errCommit)),
+		)
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.Phase).To(Equal(workflow.PhaseCommit))
+		Expect(werr.Index).To(Equal(1))
+		Expect(werr.Handler).NotTo(BeEmpty())
+		Expect(werr.RollbackErrors).To(BeEmpty())
+		Expect(errors.Is(err, errCommit)).To(BeTrue())
+	})
+
+	It("keeps the original cause when rollback fails", func() {
+		_, err := workflow.StartWorkFlow(
+			nop,
+			workflow.WithCommit(fail(errCommit)),
+			workflow.WithRollback(fail(errRollback)),
+		)
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.Phase).To(Equal(workflow.PhaseCommit))
+		Expect(werr.Cause).To(Equal(errCommit))
+		Expect(werr.RollbackErrors).To(HaveLen(1))
+		Expect(errors.Is(werr.RollbackErrors[0], errRollback)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("rollback errors"))
+	})
+
+	It("collects compensation and rollback errors", func() {
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				if err := data.Step(ctx, "a", nop, fail(errors.New("a"))); err != nil {
+					return err
+				}
+				return fmt.Errorf("work")
+			},
+			workflow.WithRollback(fail(errRollback)),
+		)
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.Phase).To(Equal(workflow.PhaseWork))
+		Expect(werr.RollbackErrors).To(HaveLen(2))
+	})
+
+	It("reports rollback failures of an aborted workflow", func() {
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				data.Abort()
+				return nil
+			},
+			workflow.WithRollback(fail(errRollback)),
+		)
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.Phase).To(Equal(workflow.PhaseRollback))
+		Expect(errors.Is(err, errRollback)).To(BeTrue())
+	})
+	It("matches the cause and the rollback errors", func() {
+		errWork := errors.New("work")
+		_, err := workflow.StartWorkFlow(
+			fail(errWork),
+			workflow.WithRollback(fail(errRollback)),
+		)
+		Expect(errors.Is(err, errWork)).To(BeTrue())
+		Expect(errors.Is(err, errRollback)).To(BeTrue())
+
+		var herr *workflow.HandlerError
+		Expect(errors.As(err, &herr)).To(BeTrue())
+		Expect(herr.Phase).To(Equal(workflow.PhaseRollback))
+	})
+	It("runs every rollback handler", func() {
+		errSecond := errors.New("second")
+		released := false
+		_, err := workflow.StartWorkFlow(
+			fail(errors.New("work")),
+			workflow.WithRollback(fail(errRollback)),
+			workflow.WithRollback(fail(errSecond)),
+			workflow.WithRollback(func(ctx context.Context, data *workflow.WorkData) error {
+				released = true
+				return nil
+			}),
+		)
+		Expect(released).To(BeTrue())
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.RollbackErrors).To(HaveLen(2))
+		Expect(errors.Is(err, errRollback)).To(BeTrue())
+		Expect(errors.Is(err, errSecond)).To(BeTrue())
+	})
+
+	It("runs every finish handler", func() {
+		errFinish := errors.New("finish")
+		finished := false
+		data, err := workflow.StartWorkFlow(
+			nop,
+			workflow.WithFinish(fail(errFinish)),
+			workflow.WithFinish(func(ctx context.Context, data *workflow.WorkData) error {
+				finished = true
+				return nil
+			}),
+		)
+		Expect(finished).To(BeTrue())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeCommitted))
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.Phase).To(Equal(workflow.PhaseFinish))
+		Expect(werr.Cause).To(BeNil())
+		Expect(werr.FinishErrors).To(HaveLen(1))
+		Expect(errors.Is(err, errFinish)).To(BeTrue())
+		Expect(strings.Count(err.Error(), "finish handler #0")).To(Equal(1))
+	})
+})
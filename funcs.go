@@ -2,18 +2,20 @@ package workflow
 
 import (
 	"context"
-	"math"
+	"fmt"
 	"sync"
 )
 
-const abortIndex int8 = math.MaxInt8 / 2
-
 type Event = func(ctx context.Context, data *WorkData) error
 
 type funcs struct {
-	mux   sync.Mutex
-	fs    []Event
-	index int8
+	mux       sync.Mutex
+	fs        []Event
+	phase     Phase
+	index     int
+	aborted   bool
+	completed []bool
+	errs      []error
 }
 
 func NewFuncs() *funcs {
@@ -23,34 +25,163 @@ func NewFuncs() *funcs {
 	}
 }
 
+func newPhaseFuncs(phase Phase) *funcs {
+	fs := NewFuncs()
+	fs.phase = phase
+	return fs
+}
+
+func (fs *funcs) clone() *funcs {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	n := len(fs.fs)
+	return &funcs{
+		fs:        fs.fs[:n:n],
+		phase:     fs.phase,
+		index:     -1,
+		completed: make([]bool, n),
+	}
+}
+
+func (fs *funcs) validate() error {
+	for i, f := range fs.fs {
+		if f == nil {
+			return fmt.Errorf("%v handler #%d is nil", fs.phase, i)
+		}
+	}
+	return nil
+}
+
 func (fs *funcs) Add(f Event) {
 	fs.mux.Lock()
 	defer fs.mux.Unlock()
 
 	fs.fs = append(fs.fs, f)
+	fs.completed = append(fs.completed, false)
 }
 
+// next runs the remaining handlers of the phase. Forward phases stop at the
+// first failing handler, while rollback and finish handlers all run so that
+// every resource gets released; their errors are collected and the first one
+// is returned.
 func (fs *funcs) next(ctx context.Context, data *WorkData) error {
+	cleanup := fs.phase == PhaseRollback || fs.phase == PhaseFinish
+
 	fs.index++
-	for fs.index < int8(len(fs.fs)) {
-		err := fs.fs[fs.index](ctx, data)
+	for !fs.isAborted() && fs.index < len(fs.fs) {
+		f := fs.fs[fs.index]
+		if err := ctx.Err(); err != nil && !cleanup {
+			data.AbortWithReason(err)
+			return nil
+		}
+
+		index := fs.index
+		info := HandlerInfo{Phase: fs.phase, Index: index, Name: handlerName(f)}
+		err := data.observers.handle(ctx, data, info, data.wrapRetry(fs.phase, f))
 		if err != nil {
-			fs.abort()
-			return err
+			if !cleanup {
+				return fs.handlerError(f, err)
+			}
+			fs.addError(fs.newHandlerError(f, err))
+		}
+		fs.markCompleted(index)
+		if err := data.persist(ctx, fs.phase, fs.completedCount()); err != nil {
+			if cleanup {
+				data.Logger.WithError(err).Error("persist workflow state")
+			} else {
+				return fs.handlerError(f, fmt.Errorf("persist workflow state: %w", err))
+			}
 		}
 		fs.index++
 	}
+
+	if errs := fs.failures(); len(errs) > 0 {
+		return errs[0]
+	}
 	return nil
 }
 
+func (fs *funcs) handlerError(f Event, err error) error {
+	fs.abort()
+	return fs.newHandlerError(f, err)
+}
+
+func (fs *funcs) newHandlerError(f Event, err error) *HandlerError {
+	return &HandlerError{
+		Phase:   fs.phase,
+		Index:   fs.index,
+		Handler: handlerName(f),
+		Err:     err,
+	}
+}
+
+func (fs *funcs) addError(err error) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	fs.errs = append(fs.errs, err)
+}
+
+// failures returns the errors of the rollback or finish handlers.
+func (fs *funcs) failures() []error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	return append([]error(nil), fs.errs...)
+}
+
 func (fs *funcs) isAborted() bool {
-	return fs.index >= abortIndex
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	return fs.aborted
 }
 
 func (fs *funcs) abort() {
-	fs.index = abortIndex
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	fs.aborted = true
+}
+
+func (fs *funcs) markCompleted(index int) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	fs.completed[index] = true
+}
+
+func (fs *funcs) completedCount() int {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	n := 0
+	for _, done := range fs.completed {
+		if done {
+			n++
+		}
+	}
+	return n
+}
+
+// resume makes the next run of the phase start after the first n handlers.
+func (fs *funcs) resume(n int) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	if n > len(fs.fs) {
+		n = len(fs.fs)
+	}
+	fs.index = n - 1
+	for i := 0; i < n; i++ {
+		fs.completed[i] = true
+	}
 }
 
 func (fs *funcs) reset() {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
 	fs.index = -1
+	fs.aborted = false
+	fs.errs = nil
+	for i := range fs.completed {
+		fs.completed[i] = false
+	}
 }
@@ -0,0 +1,80 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Funcs", func() {
+	const count = 1000
+
+	counter := func(calls *int) workflow.Event {
+		return func(ctx context.Context, data *workflow.WorkData) error {
+			*calls++
+			return nil
+		}
+	}
+
+	It("runs every handler of a large registration", func() {
+		begins, commits := 0, 0
+		opts := []workflow.Options{}
+		for i := 0; i < count; i++ {
+			opts = append(opts, workflow.WithBegin(counter(&begins)), workflow.WithCommit(counter(&commits)))
+		}
+
+		data, err := workflow.StartWorkFlow(counter(new(int)), opts...)
+		Expect(err).Should(BeNil())
+		Expect(begins).To(Equal(count))
+		Expect(commits).To(Equal(count))
+		Expect(data.Progress(workflow.PhaseBegin)).To(Equal(count))
+		Expect(data.Progress(workflow.PhaseCommit)).To(Equal(count))
+		Expect(data.IsAborted()).To(BeFalse())
+	})
+
+	It("stops a large registration at the failing handler", func() {
+		commits := 0
+		opts := []workflow.Options{}
+		for i := 0; i < count; i++ {
+			i := i
+			opts = append(opts, workflow.WithCommit(func(ctx context.Context, data *workflow.WorkData) error {
+				if i == count-10 {
+					return fmt.Errorf("commit %d", i)
+				}
+				commits++
+				return nil
+			}))
+		}
+
+		data, err := workflow.StartWorkFlow(counter(new(int)), opts...)
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.Index).To(Equal(count - 10))
+		Expect(commits).To(Equal(count - 10))
+		Expect(data.Progress(workflow.PhaseCommit)).To(Equal(count - 10))
+	})
+
+	It("stops a large registration at the aborting handler", func() {
+		begins := 0
+		opts := []workflow.Options{}
+		for i := 0; i < count; i++ {
+			i := i
+			opts = append(opts, workflow.WithBegin(func(ctx context.Context, data *workflow.WorkData) error {
+				begins++
+				if i == 100 {
+					data.Abort()
+				}
+				return nil
+			}))
+		}
+
+		data, err := workflow.StartWorkFlow(counter(new(int)), opts...)
+		Expect(err).Should(BeNil())
+		Expect(begins).To(Equal(101))
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeAborted))
+	})
+})
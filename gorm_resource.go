@@ -0,0 +1,131 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	gormV2 "gorm.io/gorm"
+)
+
+type gormResource struct {
+	name string
+	db   *gormV2.DB
+	opts *GormTxOptions
+	key  *Key[*gormV2.DB]
+}
+
+// NewGormResource returns a Resource running a gorm transaction named name,
+// available through MustGetGormTxNamed. On postgres the transaction is
+// prepared with PREPARE TRANSACTION, so it survives a crash of the process
+// and is committed or rolled back by Recover. Other dialects have no prepare
+// step, their transactions are not durable and are lost with the process.
+func NewGormResource(name string, db *gormV2.DB, opts *GormTxOptions) Resource {
+	if opts == nil {
+		opts = &GormTxOptions{}
+	}
+	return &gormResource{name: name, db: db, opts: opts, key: gormTxKeyNamed(name)}
+}
+
+// WithGormV2Resource runs the workflow in a gorm transaction taking part in
+// the two-phase commit of the workflow resources.
+func WithGormV2Resource(name string, db *gormV2.DB, opts *GormTxOptions) Options {
+	r := NewGormResource(name, db, opts).(*gormResource)
+	return applyFunc(func(data *WorkData) {
+		r.opts.apply(data)
+		WithResource(r).Apply(data)
+	})
+}
+
+type gormPreparedKey struct{ name string }
+
+func (r *gormResource) Name() string {
+	return r.name
+}
+
+func (r *gormResource) Durable() bool {
+	return r.canPrepare()
+}
+
+func (r *gormResource) Begin(ctx context.Context, data *WorkData) error {
+	tx, err := r.opts.begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	setGormTx(data, r.key, tx)
+	return nil
+}
+
+func (r *gormResource) Prepare(ctx context.Context, data *WorkData) error {
+	if !r.canPrepare() {
+		return nil
+	}
+	tx := r.key.MustGet(data)
+	if err := tx.Exec("PREPARE TRANSACTION " + r.gid(data)).Error; err != nil {
+		return err
+	}
+	data.setLocal(gormPreparedKey{name: r.name}, true)
+	return nil
+}
+
+func (r *gormResource) Commit(ctx context.Context, data *WorkData) error {
+	if r.canPrepare() {
+		if err := r.db.Exec("COMMIT PREPARED " + r.gid(data)).Error; err != nil {
+			return err
+		}
+		r.release(data)
+		return nil
+	}
+
+	tx, err := r.key.Get(data)
+	if err != nil {
+		return fmt.Errorf("commit transaction %q: %w", r.name, err)
+	}
+	return tx.Commit().Error
+}
+
+func (r *gormResource) Rollback(ctx context.Context, data *WorkData) error {
+	if _, ok := data.getLocal(gormPreparedKey{name: r.name}); ok || (r.canPrepare() && r.recovered(data)) {
+		if err := r.db.Exec("ROLLBACK PREPARED " + r.gid(data)).Error; err != nil {
+			return err
+		}
+		r.release(data)
+		return nil
+	}
+
+	tx, err := r.key.Get(data)
+	if err != nil {
+		// the transaction was lost with the process which began it.
+		return nil
+	}
+	if err := tx.Rollback().Error; err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return err
+	}
+	return nil
+}
+
+func (r *gormResource) canPrepare() bool {
+	return r.db.Dialector.Name() == "postgres"
+}
+
+// recovered tells whether data was restored from the store, so the
+// transaction was not begun by this process.
+func (r *gormResource) recovered(data *WorkData) bool {
+	_, err := r.key.Get(data)
+	return err != nil
+}
+
+// release returns the connection of a prepared transaction to the pool, the
+// transaction itself was already ended by PREPARE TRANSACTION.
+func (r *gormResource) release(data *WorkData) {
+	if tx, err := r.key.Get(data); err == nil {
+		tx.Rollback()
+	}
+}
+
+// gid is the quoted global identifier of the prepared transaction.
+func (r *gormResource) gid(data *WorkData) string {
+	return "'" + strings.ReplaceAll(data.ID()+"/"+r.name, "'", "''") + "'"
+}
@@ -0,0 +1,93 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	gormV2 "gorm.io/gorm"
+)
+
+type gormState struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	Name      string `gorm:"size:255"`
+	Phase     int
+	Progress  int
+	Values    string `gorm:"type:text"`
+	InDoubt   string `gorm:"type:text"`
+	Done      bool   `gorm:"index"`
+	Outcome   int
+	UpdatedAt time.Time
+}
+
+func (gormState) TableName() string {
+	return "workflow_states"
+}
+
+// GormStore keeps workflow states in the workflow_states table. Its db must
+// not be the transaction of the workflow, or the state would be rolled back
+// with it.
+type GormStore struct {
+	db *gormV2.DB
+}
+
+func NewGormStore(db *gormV2.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+func (s *GormStore) AutoMigrate() error {
+	return s.db.AutoMigrate(&gormState{})
+}
+
+func (s *GormStore) Save(ctx context.Context, state *State) error {
+	values, err := json.Marshal(state.Values)
+	if err != nil {
+		return err
+	}
+	inDoubt, err := json.Marshal(state.InDoubt)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Save(&gormState{
+		ID:        state.ID,
+		Name:      state.Name,
+		Phase:     int(state.Phase),
+		Progress:  state.Progress,
+		Values:    string(values),
+		InDoubt:   string(inDoubt),
+		Done:      state.Done,
+		Outcome:   int(state.Outcome),
+		UpdatedAt: state.UpdatedAt,
+	}).Error
+}
+
+func (s *GormStore) Incomplete(ctx context.Context) ([]*State, error) {
+	var rows []gormState
+	if err := s.db.WithContext(ctx).Where("done = ?", false).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	states := make([]*State, 0, len(rows))
+	for _, row := range rows {
+		state := &State{
+			ID:        row.ID,
+			Name:      row.Name,
+			Phase:     Phase(row.Phase),
+			Progress:  row.Progress,
+			Done:      row.Done,
+			Outcome:   Outcome(row.Outcome),
+			UpdatedAt: row.UpdatedAt,
+		}
+		if err := json.Unmarshal([]byte(row.Values), &state.Values); err != nil {
+			return nil, err
+		}
+		if row.InDoubt != "" {
+			if err := json.Unmarshal([]byte(row.InDoubt), &state.InDoubt); err != nil {
+				return nil, err
+			}
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
@@ -0,0 +1,229 @@
+package workflow_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gorm.io/driver/sqlite"
+	gormV2 "gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/logger"
+)
+
+type testOrder struct {
+	ID uint
+}
+
+// fakeDialector runs gorm on a database opened with the fake driver.
+type fakeDialector struct {
+	sqlite.Dialector
+	conn *sql.DB
+}
+
+func (d fakeDialector) Initialize(db *gormV2.DB) error {
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{})
+	db.ConnPool = d.conn
+	return nil
+}
+
+func openFakeGormDB() (*gormV2.DB, *fakeDriver) {
+	conn, d := openFakeDB()
+	db, err := gormV2.Open(fakeDialector{conn: conn}, &gormV2.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	Expect(err).Should(BeNil())
+	return db, d
+}
+
+var _ = Describe("WithGormV2Named", func() {
+	var orders, audit *gormV2.DB
+
+	BeforeEach(func() {
+		orders = openTestDB()
+		Expect(orders.AutoMigrate(&testOrder{})).Should(BeNil())
+		audit = openTestDB()
+		Expect(audit.AutoMigrate(&testOrder{})).Should(BeNil())
+	})
+
+	count := func(db *gormV2.DB, model interface{}) int64 {
+		var n int64
+		Expect(db.Model(model).Count(&n).Error).Should(BeNil())
+		return n
+	}
+
+	It("keeps a transaction per name", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			ordersTx := workflow.MustGetGormTxNamed(data, "orders")
+			auditTx := workflow.MustGetGormTxNamed(data, "audit")
+			Expect(ordersTx).NotTo(BeIdenticalTo(auditTx))
+			if err := ordersTx.Create(&testOrder{ID: 1}).Error; err != nil {
+				return err
+			}
+			return auditTx.Create(&testOrder{ID: 2}).Error
+		},
+			workflow.WithGormV2Named("orders", orders, nil),
+			workflow.WithGormV2Named("audit", audit, nil),
+		)
+		Expect(err).Should(BeNil())
+		Expect(count(orders, &testOrder{})).To(BeEquivalentTo(1))
+		Expect(count(audit, &testOrder{})).To(BeEquivalentTo(1))
+	})
+
+	It("keeps WithGormV2 under GormDBKey", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			Expect(workflow.MustGetGormTxNamed(data, workflow.GormDBKey)).To(BeIdenticalTo(workflow.MustGetGormTx(data)))
+			return nil
+		}, workflow.WithGormV2(orders))
+		Expect(err).Should(BeNil())
+	})
+
+	It("rolls back every transaction when the work fails", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			Expect(workflow.MustGetGormTxNamed(data, "orders").Create(&testOrder{ID: 1}).Error).Should(BeNil())
+			Expect(workflow.MustGetGormTxNamed(data, "audit").Create(&testOrder{ID: 1}).Error).Should(BeNil())
+			return errors.New("work")
+		},
+			workflow.WithGormV2Named("orders", orders, nil),
+			workflow.WithGormV2Named("audit", audit, nil),
+		)
+		Expect(err).ShouldNot(BeNil())
+		Expect(count(orders, &testOrder{})).To(BeZero())
+		Expect(count(audit, &testOrder{})).To(BeZero())
+	})
+
+	It("rolls back the remaining transactions when a commit fails", func() {
+		failing, d := openFakeGormDB()
+		d.commitErr = errors.New("commit")
+
+		data, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			return workflow.MustGetGormTxNamed(data, "orders").Create(&testOrder{ID: 1}).Error
+		},
+			workflow.WithGormV2Named("failing", failing, nil),
+			workflow.WithGormV2Named("orders", orders, nil),
+		)
+		Expect(err).Should(MatchError(ContainSubstring("commit")))
+		var perr *workflow.PartialCommitError
+		Expect(errors.As(err, &perr)).To(BeFalse())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRolledBack))
+		Expect(d.done).To(Equal([]string{"commit"}))
+		Expect(count(orders, &testOrder{})).To(BeZero())
+	})
+
+	It("reports partially committed transactions", func() {
+		failing, d := openFakeGormDB()
+		d.commitErr = errors.New("commit")
+
+		data, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			return workflow.MustGetGormTxNamed(data, "orders").Create(&testOrder{ID: 1}).Error
+		},
+			workflow.WithGormV2Named("orders", orders, nil),
+			workflow.WithGormV2Named("failing", failing, nil),
+		)
+		Expect(err).ShouldNot(BeNil())
+
+		var perr *workflow.PartialCommitError
+		Expect(errors.As(err, &perr)).To(BeTrue())
+		Expect(perr.Committed).To(Equal([]string{"orders"}))
+		Expect(perr.Failed).To(Equal("failing"))
+
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.Phase).To(Equal(workflow.PhaseCommit))
+		Expect(werr.RollbackErrors).To(HaveLen(1))
+		Expect(errors.Is(err, workflow.ErrAlreadyCommitted)).To(BeTrue())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRollbackFailed))
+		Expect(count(orders, &testOrder{})).To(BeEquivalentTo(1))
+	})
+
+	Describe("GormTxOptions", func() {
+		failTwice := func(calls *int) workflow.Event {
+			return func(ctx context.Context, data *workflow.WorkData) error {
+				*calls++
+				if *calls <= 2 {
+					return sqlStateError("40001")
+				}
+				return nil
+			}
+		}
+
+		It("begins the transaction with the options", func() {
+			fake, d := openFakeGormDB()
+
+			_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+				return nil
+			}, workflow.WithGormV2Named("fake", fake, &workflow.GormTxOptions{
+				TxOptions: &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true},
+			}))
+			Expect(err).Should(BeNil())
+			Expect(d.txs).To(Equal([]driver.TxOptions{{Isolation: driver.IsolationLevel(sql.LevelRepeatableRead), ReadOnly: true}}))
+		})
+
+		It("propagates the context to the transaction", func() {
+			ctx, cancel := context.WithCancel(context.WithValue(context.Background(), observedKey{}, "begin"))
+			defer cancel()
+
+			_, err := workflow.StartWorkFlowContext(ctx, func(ctx context.Context, data *workflow.WorkData) error {
+				txCtx := workflow.MustGetGormTxNamed(data, "orders").Statement.Context
+				Expect(txCtx.Value(observedKey{})).To(Equal("begin"))
+				return nil
+			}, workflow.WithGormV2Named("orders", orders, nil))
+			Expect(err).Should(BeNil())
+		})
+
+		It("configures the session", func() {
+			hooked := 0
+			hook := func(ctx context.Context, data *workflow.WorkData) error {
+				return workflow.MustGetGormTxNamed(data, "orders").Create(&hookedOrder{ID: 1, calls: &hooked}).Error
+			}
+			Expect(orders.AutoMigrate(&hookedOrder{})).Should(BeNil())
+
+			_, err := workflow.StartWorkFlow(hook, workflow.WithGormV2Named("orders", orders, &workflow.GormTxOptions{
+				Session: &gormV2.Session{SkipHooks: true},
+			}))
+			Expect(err).Should(BeNil())
+			Expect(hooked).To(BeZero())
+			Expect(count(orders, &hookedOrder{})).To(BeEquivalentTo(1))
+		})
+
+		It("retries serializable workflows on serialization failures", func() {
+			fake, _ := openFakeGormDB()
+			calls := 0
+
+			data, err := workflow.StartWorkFlow(
+				failTwice(&calls),
+				workflow.WithGormV2Named("fake", fake, &workflow.GormTxOptions{
+					TxOptions: &sql.TxOptions{Isolation: sql.LevelSerializable},
+				}),
+			)
+			Expect(err).Should(BeNil())
+			Expect(data.Attempt()).To(Equal(3))
+		})
+
+		It("does not retry other isolation levels", func() {
+			fake, _ := openFakeGormDB()
+			calls := 0
+
+			data, err := workflow.StartWorkFlow(
+				failTwice(&calls),
+				workflow.WithGormV2Named("fake", fake, nil),
+			)
+			Expect(err).ShouldNot(BeNil())
+			Expect(data.Attempt()).To(Equal(1))
+		})
+	})
+})
+
+type hookedOrder struct {
+	ID    uint
+	calls *int
+}
+
+func (o *hookedOrder) BeforeCreate(tx *gormV2.DB) error {
+	*o.calls++
+	return nil
+}
@@ -0,0 +1,65 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrKeyNotFound = errors.New("key not found")
+
+type KeyNotFoundError struct {
+	Key string
+}
+
+func (e *KeyNotFoundError) Error() string {
+	return fmt.Sprintf("key: %v is not exists", e.Key)
+}
+
+func (e *KeyNotFoundError) Is(target error) bool {
+	return target == ErrKeyNotFound
+}
+
+// Key is a typed key of the WorkData context. Keys are compared by identity,
+// so two keys created with the same name never collide.
+type Key[T any] struct {
+	name string
+}
+
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+func (k *Key[T]) String() string {
+	return k.name
+}
+
+func (k *Key[T]) Get(data *WorkData) (T, error) {
+	if value, ok := data.get(k); ok {
+		// a nil value of an interface type T is stored as a nil interface{}.
+		v, _ := value.(T)
+		return v, nil
+	}
+
+	var zero T
+	return zero, &KeyNotFoundError{Key: k.name}
+}
+
+func (k *Key[T]) MustGet(data *WorkData) T {
+	value, err := k.Get(data)
+	if err != nil {
+		panic(err.Error())
+	}
+	return value
+}
+
+func (k *Key[T]) Set(data *WorkData, value T) {
+	data.set(k, value)
+}
+
+func GetT[T any](data *WorkData, key *Key[T]) (T, error) {
+	return key.Get(data)
+}
+
+func SetT[T any](data *WorkData, key *Key[T], value T) {
+	key.Set(data, value)
+}
@@ -0,0 +1,62 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Key", func() {
+	orderID := workflow.NewKey[int]("order")
+	otherOrderID := workflow.NewKey[string]("order")
+
+	It("stores typed values", func() {
+		data, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			orderID.Set(data, 42)
+			workflow.SetT(data, otherOrderID, "plugin")
+			return nil
+		})
+		Expect(err).Should(BeNil())
+		Expect(orderID.MustGet(data)).To(Equal(42))
+		Expect(workflow.GetT(data, otherOrderID)).To(Equal("plugin"))
+		Expect(data.Get("order")).Should(BeNil())
+	})
+
+	It("returns a typed error for a missing key", func() {
+		data := workflow.NewWorkData()
+		value, err := orderID.Get(data)
+		Expect(value).To(BeZero())
+		Expect(errors.Is(err, workflow.ErrKeyNotFound)).To(BeTrue())
+		var kerr *workflow.KeyNotFoundError
+		Expect(errors.As(err, &kerr)).To(BeTrue())
+		Expect(kerr.Key).To(Equal("order"))
+		Expect(func() { orderID.MustGet(data) }).To(Panic())
+	})
+
+	It("stores nil values of interface types", func() {
+		lastErr := workflow.NewKey[error]("last-error")
+		data := workflow.NewWorkData()
+		lastErr.Set(data, nil)
+
+		value, err := lastErr.Get(data)
+		Expect(err).Should(BeNil())
+		Expect(value).Should(BeNil())
+	})
+
+	It("returns a typed error when there is no gorm transaction", func() {
+		_, err := workflow.GetGormTx(workflow.NewWorkData())
+		Expect(errors.Is(err, workflow.ErrKeyNotFound)).To(BeTrue())
+	})
+
+	It("keeps the gorm transaction under GormDBKey", func() {
+		db := openTestDB()
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			Expect(data.MustGet(workflow.GormDBKey)).To(BeIdenticalTo(workflow.MustGetGormTx(data)))
+			return nil
+		}, workflow.WithGormV2(db))
+		Expect(err).Should(BeNil())
+	})
+})
@@ -0,0 +1,205 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger is the logger used by the engine and exposed to the Events as
+// WorkData.Logger. Adapters are provided for logrus, and for slog and zap in
+// the slogger and zaplogger packages.
+type Logger interface {
+	WithField(key string, value interface{}) Logger
+	WithError(err error) Logger
+	Debug(msg string)
+	Info(msg string)
+	Warn(msg string)
+	Error(msg string)
+}
+
+type Level int
+
+const (
+	LevelDebug Level = iota + 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// Log logs msg with logger at level.
+func Log(logger Logger, level Level, msg string) {
+	switch level {
+	case LevelDebug:
+		logger.Debug(msg)
+	case LevelInfo:
+		logger.Info(msg)
+	case LevelWarn:
+		logger.Warn(msg)
+	case LevelError:
+		logger.Error(msg)
+	}
+}
+
+// WithLog sets the logger of the workflow, WithLogger is the shortcut for
+// logrus.
+func WithLog(logger Logger) Options {
+	return applyFunc(func(data *WorkData) {
+		data.Logger = logger.WithField("from", "workflow")
+	})
+}
+
+// LogLevels are the levels the engine logs the lifecycle of the runs at,
+// zero fields keep their default.
+type LogLevels struct {
+	// Phase is used for the start and end of runs and phases, debug by default.
+	Phase Level
+	// Rollback is used for aborts and successful rollbacks, warn by default.
+	Rollback Level
+	// Failure is used for failed handlers, phases and rollbacks, error by default.
+	Failure Level
+}
+
+var defaultLogLevels = LogLevels{
+	Phase:    LevelDebug,
+	Rollback: LevelWarn,
+	Failure:  LevelError,
+}
+
+func WithLogLevels(levels LogLevels) Options {
+	return applyFunc(func(data *WorkData) {
+		if levels.Phase != 0 {
+			data.logLevels.Phase = levels.Phase
+		}
+		if levels.Rollback != 0 {
+			data.logLevels.Rollback = levels.Rollback
+		}
+		if levels.Failure != 0 {
+			data.logLevels.Failure = levels.Failure
+		}
+	})
+}
+
+type logrusLogger struct {
+	entry *logrus.Entry
+}
+
+func Logrus(entry *logrus.Entry) Logger {
+	return logrusLogger{entry: entry}
+}
+
+func (l logrusLogger) WithField(key string, value interface{}) Logger {
+	return logrusLogger{entry: l.entry.WithField(key, value)}
+}
+
+func (l logrusLogger) WithError(err error) Logger {
+	return logrusLogger{entry: l.entry.WithError(err)}
+}
+
+func (l logrusLogger) Debug(msg string) { l.entry.Debug(msg) }
+func (l logrusLogger) Info(msg string)  { l.entry.Info(msg) }
+func (l logrusLogger) Warn(msg string)  { l.entry.Warn(msg) }
+func (l logrusLogger) Error(msg string) { l.entry.Error(msg) }
+
+// NopLogger discards everything.
+type NopLogger struct{}
+
+func (l NopLogger) WithField(key string, value interface{}) Logger { return l }
+func (l NopLogger) WithError(err error) Logger                     { return l }
+func (NopLogger) Debug(msg string)                                 {}
+func (NopLogger) Info(msg string)                                  {}
+func (NopLogger) Warn(msg string)                                  {}
+func (NopLogger) Error(msg string)                                 {}
+
+// logObserver logs the lifecycle of the runs with WorkData.Logger, it is the
+// first observer of every workflow.
+type logObserver struct {
+	NopObserver
+}
+
+func (logObserver) OnStart(ctx context.Context, data *WorkData) context.Context {
+	Log(data.Logger, data.logLevels.Phase, "workflow started")
+	return ctx
+}
+
+func (logObserver) OnEnd(ctx context.Context, data *WorkData, result Result) {
+	logger := data.Logger.
+		WithField("outcome", result.Outcome.String()).
+		WithField("duration", data.endedAt.Sub(data.startedAt))
+	level := data.logLevels.Phase
+	switch {
+	case result.Err != nil:
+		logger = logger.WithError(result.Err)
+		level = data.logLevels.Failure
+	case result.Outcome != OutcomeCommitted:
+		if result.AbortReason != nil {
+			logger = logger.WithField("reason", result.AbortReason.Error())
+		}
+		level = data.logLevels.Rollback
+	}
+	Log(logger, level, "workflow ended")
+}
+
+func (logObserver) OnPhaseStart(ctx context.Context, data *WorkData, phase Phase) context.Context {
+	Log(data.Logger.WithField("phase", phase.String()), data.logLevels.Phase, "phase started")
+	return ctx
+}
+
+func (logObserver) OnPhaseEnd(ctx context.Context, data *WorkData, phase Phase, err error, duration time.Duration) {
+	logger := data.Logger.WithField("phase", phase.String()).WithField("duration", duration)
+	if err != nil {
+		Log(logger.WithError(err), data.logLevels.Failure, "phase failed")
+		return
+	}
+	Log(logger, data.logLevels.Phase, "phase ended")
+}
+
+func (logObserver) OnHandlerEnd(ctx context.Context, data *WorkData, handler HandlerInfo, err error, duration time.Duration) {
+	if err == nil {
+		return
+	}
+	logger := data.Logger.
+		WithField("phase", handler.Phase.String()).
+		WithField("handler", handler.Name).
+		WithField("index", handler.Index).
+		WithField("duration", duration)
+	Log(logger.WithError(err), data.logLevels.Failure, "handler failed")
+}
+
+func (logObserver) OnAbort(ctx context.Context, data *WorkData, reason error) {
+	logger := data.Logger
+	if reason != nil {
+		logger = logger.WithField("reason", reason.Error())
+	}
+	Log(logger, data.logLevels.Rollback, "workflow aborted")
+}
+
+func (logObserver) OnPanic(ctx context.Context, data *WorkData, err *PanicError) {
+	Log(data.Logger.WithError(err).WithField("stack", string(err.Stack)), data.logLevels.Failure, "handler panicked")
+}
+
+func (logObserver) OnRollback(ctx context.Context, data *WorkData, errs []error) {
+	if len(errs) == 0 {
+		Log(data.Logger, data.logLevels.Rollback, "workflow rolled back")
+		return
+	}
+	for _, err := range errs {
+		Log(data.Logger.WithError(err), data.logLevels.Failure, "rollback failed")
+	}
+}
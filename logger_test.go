@@ -0,0 +1,107 @@
+package workflow_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Logger", func() {
+	var buf *bytes.Buffer
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+	})
+
+	jsonLogger := func() *logrus.Entry {
+		logger := logrus.New()
+		logger.SetOutput(buf)
+		logger.SetLevel(logrus.DebugLevel)
+		logger.SetFormatter(&logrus.JSONFormatter{DisableTimestamp: true})
+		return logrus.NewEntry(logger)
+	}
+
+	It("logs with the workflow logger", func() {
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				data.Logger.WithField("order", 42).Info("working")
+				return nil
+			},
+			workflow.WithLogger(jsonLogger()),
+		)
+		Expect(err).Should(BeNil())
+		Expect(buf.String()).To(ContainSubstring(`"level":"info","msg":"working","order":42`))
+	})
+
+	It("logs the phase transitions", func() {
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				return nil
+			},
+			workflow.WithLogger(jsonLogger()),
+		)
+		Expect(err).Should(BeNil())
+		Expect(buf.String()).To(ContainSubstring(`"level":"debug","msg":"workflow started"`))
+		Expect(buf.String()).To(ContainSubstring(`"level":"debug","msg":"phase started","phase":"commit"`))
+		Expect(buf.String()).To(ContainSubstring(`"msg":"workflow ended"`))
+		Expect(buf.String()).To(ContainSubstring(`"outcome":"committed"`))
+	})
+
+	It("logs failures and rollbacks", func() {
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				return errors.New("work")
+			},
+			workflow.WithLogger(jsonLogger()),
+		)
+		Expect(err).ShouldNot(BeNil())
+		Expect(buf.String()).To(ContainSubstring(`"level":"error","msg":"handler failed"`))
+		Expect(buf.String()).To(ContainSubstring(`"level":"warning","msg":"workflow rolled back"`))
+		Expect(buf.String()).To(ContainSubstring(`"outcome":"rolledBack"`))
+	})
+
+	It("logs at the configured levels", func() {
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				data.Abort()
+				return nil
+			},
+			workflow.WithLogger(jsonLogger()),
+			workflow.WithLogLevels(workflow.LogLevels{Phase: workflow.LevelInfo, Rollback: workflow.LevelError}),
+		)
+		Expect(err).Should(BeNil())
+		Expect(buf.String()).To(ContainSubstring(`"level":"info","msg":"workflow started"`))
+		Expect(buf.String()).To(ContainSubstring(`"level":"error","msg":"workflow aborted"`))
+	})
+
+	It("keeps logging with a logrus entry", func() {
+		logger := logrus.New()
+		logger.SetOutput(buf)
+		logger.SetLevel(logrus.DebugLevel)
+
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				return nil
+			},
+			workflow.WithLogger(logrus.NewEntry(logger)),
+		)
+		Expect(err).Should(BeNil())
+		Expect(buf.String()).To(ContainSubstring(`msg="workflow started" from=workflow`))
+	})
+
+	It("discards everything with NopLogger", func() {
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				data.Logger.Error("discarded")
+				return errors.New("work")
+			},
+			workflow.WithLog(workflow.NopLogger{}),
+		)
+		Expect(err).ShouldNot(BeNil())
+	})
+})
@@ -0,0 +1,100 @@
+package workflow
+
+import (
+	"context"
+	"time"
+)
+
+// RunInfo identifies a workflow run. It is carried by the context passed to
+// every Event, so downstream calls can be correlated with the run.
+type RunInfo struct {
+	ID       string
+	ParentID string
+	Name     string
+	Labels   map[string]string
+}
+
+type runInfoKey struct{}
+
+func RunInfoFromContext(ctx context.Context) (RunInfo, bool) {
+	info, ok := ctx.Value(runInfoKey{}).(RunInfo)
+	return info, ok
+}
+
+func WithIDGenerator(generate func() string) Options {
+	return applyFunc(func(data *WorkData) {
+		data.idGenerator = generate
+	})
+}
+
+// WithParentID links the run to a workflow running elsewhere, sub workflows
+// are linked to their parent automatically.
+func WithParentID(id string) Options {
+	return applyFunc(func(data *WorkData) {
+		data.parentID = id
+	})
+}
+
+func WithLabels(labels map[string]string) Options {
+	return applyFunc(func(data *WorkData) {
+		for k, v := range labels {
+			data.labels[k] = v
+		}
+	})
+}
+
+func WithLabel(key, value string) Options {
+	return WithLabels(map[string]string{key: value})
+}
+
+func (d *WorkData) ParentID() string {
+	return d.parentID
+}
+
+func (d *WorkData) Labels() map[string]string {
+	labels := make(map[string]string, len(d.labels))
+	for k, v := range d.labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+func (d *WorkData) StartedAt() time.Time {
+	return d.startedAt
+}
+
+func (d *WorkData) EndedAt() time.Time {
+	return d.endedAt
+}
+
+func (d *WorkData) RunInfo() RunInfo {
+	return RunInfo{
+		ID:       d.id,
+		ParentID: d.parentID,
+		Name:     d.name,
+		Labels:   d.Labels(),
+	}
+}
+
+// annotate starts the run: the logger and the returned context carry the
+// identity of the run from now on.
+func (d *WorkData) annotate(ctx context.Context) context.Context {
+	d.startedAt = time.Now()
+
+	logger := d.Logger.WithField("id", d.id)
+	if d.parentID != "" {
+		logger = logger.WithField("parent_id", d.parentID)
+	}
+	for k, v := range d.labels {
+		logger = logger.WithField(k, v)
+	}
+	d.Logger = logger
+
+	ctx = context.WithValue(ctx, runInfoKey{}, d.RunInfo())
+	return d.observers.start(ctx, d)
+}
+
+func (d *WorkData) finalize(ctx context.Context) {
+	d.endedAt = time.Now()
+	d.observers.end(ctx, d, d.Result())
+}
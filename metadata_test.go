@@ -0,0 +1,85 @@
+package workflow_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Metadata", func() {
+	It("identifies every run", func() {
+		seq := 0
+		w := workflow.MustDefine(
+			"order",
+			workflow.WithIDGenerator(func() string {
+				seq++
+				return fmt.Sprintf("run-%d", seq)
+			}),
+			workflow.WithLabel("tenant", "acme"),
+		)
+
+		var info workflow.RunInfo
+		data, err := w.Run(context.Background(), func(ctx context.Context, data *workflow.WorkData) error {
+			info, _ = workflow.RunInfoFromContext(ctx)
+			return nil
+		})
+		Expect(err).Should(BeNil())
+		Expect(data.ID()).To(Equal("run-1"))
+		Expect(info).To(Equal(workflow.RunInfo{
+			ID:     "run-1",
+			Name:   "order",
+			Labels: map[string]string{"tenant": "acme"},
+		}))
+		Expect(data.StartedAt()).NotTo(BeZero())
+		Expect(data.EndedAt()).NotTo(BeTemporally("<", data.StartedAt()))
+
+		data, err = w.Run(context.Background(), func(ctx context.Context, data *workflow.WorkData) error {
+			return nil
+		})
+		Expect(err).Should(BeNil())
+		Expect(data.ID()).To(Equal("run-2"))
+	})
+
+	It("links sub workflows to their parent", func() {
+		var parentID, childParentID string
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			parentID = data.ID()
+			child, err := data.Sub(ctx, func(ctx context.Context, data *workflow.WorkData) error {
+				info, _ := workflow.RunInfoFromContext(ctx)
+				childParentID = info.ParentID
+				return nil
+			})
+			Expect(child.ParentID()).To(Equal(parentID))
+			return err
+		})
+		Expect(err).Should(BeNil())
+		Expect(parentID).NotTo(BeEmpty())
+		Expect(childParentID).To(Equal(parentID))
+	})
+
+	It("adds the metadata to the log fields", func() {
+		buf := &bytes.Buffer{}
+		logger := logrus.New()
+		logger.SetOutput(buf)
+		logger.SetFormatter(&logrus.JSONFormatter{})
+
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				data.Logger.Info("working")
+				return nil
+			},
+			workflow.WithLogger(logrus.NewEntry(logger)),
+			workflow.WithParentID("upstream"),
+			workflow.WithLabels(map[string]string{"tenant": "acme"}),
+		)
+		Expect(err).Should(BeNil())
+		Expect(buf.String()).To(ContainSubstring(`"parent_id":"upstream"`))
+		Expect(buf.String()).To(ContainSubstring(`"tenant":"acme"`))
+		Expect(buf.String()).To(ContainSubstring(`"id":"`))
+	})
+})
@@ -0,0 +1,118 @@
+// Package metrics records Prometheus metrics of workflow runs through a
+// workflow.Observer.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/chein-huang/workflow"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is both a workflow.Observer and a prometheus.Collector:
+//
+//	collector := metrics.NewCollector("app")
+//	prometheus.MustRegister(collector)
+//	workflow.StartWorkFlow(work, workflow.WithObserver(collector))
+type Collector struct {
+	workflow.NopObserver
+
+	started          *prometheus.CounterVec
+	retries          *prometheus.CounterVec
+	runs             *prometheus.CounterVec
+	rollbackFailures *prometheus.CounterVec
+	phaseDuration    *prometheus.HistogramVec
+	handlerDuration  *prometheus.HistogramVec
+}
+
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		started: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "workflow_started_total",
+			Help:      "Number of started workflow runs.",
+		}, []string{"workflow"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "workflow_retries_total",
+			Help:      "Number of failed workflow attempts which were retried.",
+		}, []string{"workflow"}),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "workflow_runs_total",
+			Help:      "Number of ended workflow runs by outcome.",
+		}, []string{"workflow", "outcome"}),
+		rollbackFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "workflow_rollback_failures_total",
+			Help:      "Number of workflow runs whose rollback failed.",
+		}, []string{"workflow"}),
+		phaseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "workflow_phase_duration_seconds",
+			Help:      "Duration of workflow phases.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"workflow", "phase"}),
+		handlerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "workflow_handler_duration_seconds",
+			Help:      "Duration of workflow handlers.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"workflow", "phase", "handler"}),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.started.Describe(ch)
+	c.retries.Describe(ch)
+	c.runs.Describe(ch)
+	c.rollbackFailures.Describe(ch)
+	c.phaseDuration.Describe(ch)
+	c.handlerDuration.Describe(ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.started.Collect(ch)
+	c.retries.Collect(ch)
+	c.runs.Collect(ch)
+	c.rollbackFailures.Collect(ch)
+	c.phaseDuration.Collect(ch)
+	c.handlerDuration.Collect(ch)
+}
+
+// OnStart counts the runs once: sub workflows belong to the run of their
+// parent and retried attempts to their first attempt.
+func (c *Collector) OnStart(ctx context.Context, data *workflow.WorkData) context.Context {
+	if data.Parent() == nil && data.Attempt() == 1 {
+		c.started.WithLabelValues(data.Name()).Inc()
+	}
+	return ctx
+}
+
+// OnEnd counts the final outcome of the runs, the attempts which are retried
+// are counted as retries.
+func (c *Collector) OnEnd(ctx context.Context, data *workflow.WorkData, result workflow.Result) {
+	if data.Parent() != nil {
+		return
+	}
+	if result.Retry {
+		c.retries.WithLabelValues(data.Name()).Inc()
+		return
+	}
+	c.runs.WithLabelValues(data.Name(), result.Outcome.String()).Inc()
+}
+
+func (c *Collector) OnPhaseEnd(ctx context.Context, data *workflow.WorkData, phase workflow.Phase, err error, duration time.Duration) {
+	c.phaseDuration.WithLabelValues(data.Name(), phase.String()).Observe(duration.Seconds())
+}
+
+func (c *Collector) OnHandlerEnd(ctx context.Context, data *workflow.WorkData, handler workflow.HandlerInfo, err error, duration time.Duration) {
+	c.handlerDuration.WithLabelValues(data.Name(), handler.Phase.String(), handler.Name).Observe(duration.Seconds())
+}
+
+func (c *Collector) OnRollback(ctx context.Context, data *workflow.WorkData, errs []error) {
+	if len(errs) > 0 && data.Parent() == nil {
+		c.rollbackFailures.WithLabelValues(data.Name()).Inc()
+	}
+}
@@ -0,0 +1,118 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/chein-huang/workflow"
+	"github.com/chein-huang/workflow/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var _ = Describe("Collector", func() {
+	var (
+		collector *metrics.Collector
+		registry  *prometheus.Registry
+		order     *workflow.Workflow
+	)
+
+	BeforeEach(func() {
+		collector = metrics.NewCollector("test")
+		registry = prometheus.NewRegistry()
+		Expect(registry.Register(collector)).Should(BeNil())
+		order = workflow.MustDefine(
+			"order",
+			workflow.WithObserver(collector),
+			workflow.WithRollback(func(ctx context.Context, data *workflow.WorkData) error {
+				if _, ok := data.Get("rollback-fails"); ok {
+					return errors.New("rollback")
+				}
+				return nil
+			}),
+		)
+	})
+
+	run := func(f workflow.Event) {
+		order.Run(context.Background(), f)
+	}
+
+	It("counts the runs by outcome", func() {
+		run(func(ctx context.Context, data *workflow.WorkData) error {
+			return nil
+		})
+		run(func(ctx context.Context, data *workflow.WorkData) error {
+			return nil
+		})
+		run(func(ctx context.Context, data *workflow.WorkData) error {
+			data.Abort()
+			return nil
+		})
+		run(func(ctx context.Context, data *workflow.WorkData) error {
+			return errors.New("work")
+		})
+		run(func(ctx context.Context, data *workflow.WorkData) error {
+			data.Set("rollback-fails", true)
+			return errors.New("work")
+		})
+
+		Expect(testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP test_workflow_rollback_failures_total Number of workflow runs whose rollback failed.
+# TYPE test_workflow_rollback_failures_total counter
+test_workflow_rollback_failures_total{workflow="order"} 1
+# HELP test_workflow_runs_total Number of ended workflow runs by outcome.
+# TYPE test_workflow_runs_total counter
+test_workflow_runs_total{outcome="aborted",workflow="order"} 1
+test_workflow_runs_total{outcome="committed",workflow="order"} 2
+test_workflow_runs_total{outcome="rollbackFailed",workflow="order"} 1
+test_workflow_runs_total{outcome="rolledBack",workflow="order"} 1
+# HELP test_workflow_started_total Number of started workflow runs.
+# TYPE test_workflow_started_total counter
+test_workflow_started_total{workflow="order"} 5
+`), "test_workflow_started_total", "test_workflow_runs_total", "test_workflow_rollback_failures_total")).Should(BeNil())
+	})
+
+	It("counts sub workflows and retried attempts with their run", func() {
+		retried := workflow.MustDefine("order",
+			workflow.WithObserver(collector),
+			workflow.WithRunRetry(workflow.RetryPolicy{MaxAttempts: 3}),
+		)
+		attempts := 0
+		_, err := retried.Run(context.Background(), func(ctx context.Context, data *workflow.WorkData) error {
+			if _, err := data.Sub(ctx, func(ctx context.Context, data *workflow.WorkData) error {
+				return nil
+			}); err != nil {
+				return err
+			}
+			if attempts++; attempts < 3 {
+				return errors.New("conflict")
+			}
+			return nil
+		})
+		Expect(err).Should(BeNil())
+
+		Expect(testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP test_workflow_retries_total Number of failed workflow attempts which were retried.
+# TYPE test_workflow_retries_total counter
+test_workflow_retries_total{workflow="order"} 2
+# HELP test_workflow_runs_total Number of ended workflow runs by outcome.
+# TYPE test_workflow_runs_total counter
+test_workflow_runs_total{outcome="committed",workflow="order"} 1
+# HELP test_workflow_started_total Number of started workflow runs.
+# TYPE test_workflow_started_total counter
+test_workflow_started_total{workflow="order"} 1
+`), "test_workflow_started_total", "test_workflow_runs_total", "test_workflow_retries_total")).Should(BeNil())
+	})
+
+	It("observes phase and handler durations", func() {
+		run(func(ctx context.Context, data *workflow.WorkData) error {
+			return nil
+		})
+
+		Expect(testutil.CollectAndCount(collector, "test_workflow_phase_duration_seconds")).To(Equal(5))
+		Expect(testutil.CollectAndCount(collector, "test_workflow_handler_duration_seconds")).To(Equal(1))
+	})
+})
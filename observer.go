@@ -0,0 +1,132 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// HandlerInfo describes a handler. Compensations run in the rollback phase
+// with an Index of -1 and the name of their step.
+type HandlerInfo struct {
+	Phase Phase
+	Index int
+	Name  string
+}
+
+// Observer is notified of the lifecycle of workflow runs. The context returned
+// by the start methods is passed to the phase or handler and to the matching
+// end method, so observers can attach spans or other values to it.
+type Observer interface {
+	OnStart(ctx context.Context, data *WorkData) context.Context
+	OnEnd(ctx context.Context, data *WorkData, result Result)
+	OnPhaseStart(ctx context.Context, data *WorkData, phase Phase) context.Context
+	OnPhaseEnd(ctx context.Context, data *WorkData, phase Phase, err error, duration time.Duration)
+	OnHandlerStart(ctx context.Context, data *WorkData, handler HandlerInfo) context.Context
+	OnHandlerEnd(ctx context.Context, data *WorkData, handler HandlerInfo, err error, duration time.Duration)
+	OnAbort(ctx context.Context, data *WorkData, reason error)
+	OnPanic(ctx context.Context, data *WorkData, err *PanicError)
+	OnRollback(ctx context.Context, data *WorkData, errs []error)
+}
+
+// NopObserver implements every Observer method doing nothing, embed it to
+// implement only some of them.
+type NopObserver struct{}
+
+func (NopObserver) OnStart(ctx context.Context, data *WorkData) context.Context {
+	return ctx
+}
+
+func (NopObserver) OnEnd(ctx context.Context, data *WorkData, result Result) {}
+
+func (NopObserver) OnPhaseStart(ctx context.Context, data *WorkData, phase Phase) context.Context {
+	return ctx
+}
+
+func (NopObserver) OnPhaseEnd(ctx context.Context, data *WorkData, phase Phase, err error, duration time.Duration) {
+}
+
+func (NopObserver) OnHandlerStart(ctx context.Context, data *WorkData, handler HandlerInfo) context.Context {
+	return ctx
+}
+
+func (NopObserver) OnHandlerEnd(ctx context.Context, data *WorkData, handler HandlerInfo, err error, duration time.Duration) {
+}
+
+func (NopObserver) OnAbort(ctx context.Context, data *WorkData, reason error) {}
+
+func (NopObserver) OnPanic(ctx context.Context, data *WorkData, err *PanicError) {}
+
+func (NopObserver) OnRollback(ctx context.Context, data *WorkData, errs []error) {}
+
+func WithObserver(observer Observer) Options {
+	return applyFunc(func(data *WorkData) {
+		data.observers = append(data.observers, observer)
+	})
+}
+
+type observers []Observer
+
+func (os observers) start(ctx context.Context, data *WorkData) context.Context {
+	for _, o := range os {
+		ctx = o.OnStart(ctx, data)
+	}
+	return ctx
+}
+
+func (os observers) end(ctx context.Context, data *WorkData, result Result) {
+	for _, o := range os {
+		o.OnEnd(ctx, data, result)
+	}
+}
+
+func (os observers) phaseStart(ctx context.Context, data *WorkData, phase Phase) context.Context {
+	for _, o := range os {
+		ctx = o.OnPhaseStart(ctx, data, phase)
+	}
+	return ctx
+}
+
+func (os observers) phaseEnd(ctx context.Context, data *WorkData, phase Phase, err error, duration time.Duration) {
+	for _, o := range os {
+		o.OnPhaseEnd(ctx, data, phase, err, duration)
+	}
+}
+
+func (os observers) abort(ctx context.Context, data *WorkData, reason error) {
+	for _, o := range os {
+		o.OnAbort(ctx, data, reason)
+	}
+}
+
+func (os observers) rollback(ctx context.Context, data *WorkData, errs []error) {
+	for _, o := range os {
+		o.OnRollback(ctx, data, errs)
+	}
+}
+
+// handle runs f as the handler described by info, notifying the observers.
+func (os observers) handle(ctx context.Context, data *WorkData, info HandlerInfo, f Event) error {
+	if len(os) == 0 {
+		return safeCall(ctx, f, data)
+	}
+
+	for _, o := range os {
+		ctx = o.OnHandlerStart(ctx, data, info)
+	}
+
+	start := time.Now()
+	err := safeCall(ctx, f, data)
+	duration := time.Since(start)
+
+	var perr *PanicError
+	if errors.As(err, &perr) {
+		for _, o := range os {
+			o.OnPanic(ctx, data, perr)
+		}
+	}
+	for _, o := range os {
+		o.OnHandlerEnd(ctx, data, info, err, duration)
+	}
+	return err
+}
@@ -0,0 +1,165 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type observedKey struct{}
+
+type recordingObserver struct {
+	workflow.NopObserver
+	name   string
+	events *[]string
+}
+
+func (o *recordingObserver) record(format string, args ...interface{}) {
+	*o.events = append(*o.events, o.name+":"+fmt.Sprintf(format, args...))
+}
+
+func (o *recordingObserver) OnStart(ctx context.Context, data *workflow.WorkData) context.Context {
+	o.record("start")
+	return context.WithValue(ctx, observedKey{}, o.name)
+}
+
+func (o *recordingObserver) OnEnd(ctx context.Context, data *workflow.WorkData, result workflow.Result) {
+	o.record("end %v", result.Outcome)
+}
+
+func (o *recordingObserver) OnPhaseStart(ctx context.Context, data *workflow.WorkData, phase workflow.Phase) context.Context {
+	o.record("phase %v", phase)
+	return ctx
+}
+
+func (o *recordingObserver) OnPhaseEnd(ctx context.Context, data *workflow.WorkData, phase workflow.Phase, err error, duration time.Duration) {
+	o.record("phase %v end %v", phase, err != nil)
+}
+
+func (o *recordingObserver) OnHandlerStart(ctx context.Context, data *workflow.WorkData, handler workflow.HandlerInfo) context.Context {
+	o.record("handler %v#%d", handler.Phase, handler.Index)
+	return ctx
+}
+
+func (o *recordingObserver) OnHandlerEnd(ctx context.Context, data *workflow.WorkData, handler workflow.HandlerInfo, err error, duration time.Duration) {
+	o.record("handler %v#%d end %v", handler.Phase, handler.Index, err != nil)
+}
+
+func (o *recordingObserver) OnAbort(ctx context.Context, data *workflow.WorkData, reason error) {
+	o.record("abort %v", reason)
+}
+
+func (o *recordingObserver) OnPanic(ctx context.Context, data *workflow.WorkData, err *workflow.PanicError) {
+	o.record("panic %v", err.Value)
+}
+
+func (o *recordingObserver) OnRollback(ctx context.Context, data *workflow.WorkData, errs []error) {
+	o.record("rollback %d", len(errs))
+}
+
+var _ = Describe("Observer", func() {
+	var events []string
+
+	BeforeEach(func() {
+		events = []string{}
+	})
+
+	nop := func(ctx context.Context, data *workflow.WorkData) error {
+		return nil
+	}
+
+	It("observes a committed workflow", func() {
+		var observed interface{}
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				observed = ctx.Value(observedKey{})
+				return nil
+			},
+			workflow.WithObserver(&recordingObserver{name: "o", events: &events}),
+			workflow.WithCommit(nop),
+		)
+		Expect(err).Should(BeNil())
+		Expect(observed).To(Equal("o"))
+		Expect(events).To(Equal([]string{
+			"o:start",
+			"o:phase begin",
+			"o:phase begin end false",
+			"o:phase work",
+			"o:handler work#0",
+			"o:handler work#0 end false",
+			"o:phase work end false",
+			"o:phase beforeCommit",
+			"o:phase beforeCommit end false",
+			"o:phase commit",
+			"o:handler commit#0",
+			"o:handler commit#0 end false",
+			"o:phase commit end false",
+			"o:phase finish",
+			"o:phase finish end false",
+			"o:end committed",
+		}))
+	})
+
+	It("observes aborts, panics and rollbacks with composed observers", func() {
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				data.AbortWithReason(errors.New("stock"))
+				return nil
+			},
+			workflow.WithObserver(&recordingObserver{name: "a", events: &events}),
+			workflow.WithObserver(&recordingObserver{name: "b", events: &events}),
+			workflow.WithRollback(func(ctx context.Context, data *workflow.WorkData) error {
+				panic("boom")
+			}),
+		)
+		Expect(err).ShouldNot(BeNil())
+		Expect(events).To(ContainElements(
+			"a:abort stock",
+			"b:abort stock",
+			"a:panic boom",
+			"b:panic boom",
+			"a:handler rollback#0 end true",
+			"a:rollback 1",
+			"b:rollback 1",
+			"a:end rollbackFailed",
+			"b:end rollbackFailed",
+		))
+	})
+	It("observes compensations as rollback handlers", func() {
+		var handlers []workflow.HandlerInfo
+		observer := &handlerObserver{handlers: &handlers}
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				if err := data.Step(ctx, "reserve", nop, func(ctx context.Context, data *workflow.WorkData) error {
+					panic("boom")
+				}); err != nil {
+					return err
+				}
+				return errors.New("work")
+			},
+			workflow.WithObserver(observer),
+		)
+		Expect(err).ShouldNot(BeNil())
+		Expect(handlers).To(ContainElement(workflow.HandlerInfo{Phase: workflow.PhaseRollback, Index: -1, Name: "reserve"}))
+		Expect(observer.panics).To(Equal(1))
+	})
+})
+
+type handlerObserver struct {
+	workflow.NopObserver
+	handlers *[]workflow.HandlerInfo
+	panics   int
+}
+
+func (o *handlerObserver) OnHandlerEnd(ctx context.Context, data *workflow.WorkData, handler workflow.HandlerInfo, err error, duration time.Duration) {
+	*o.handlers = append(*o.handlers, handler)
+}
+
+func (o *handlerObserver) OnPanic(ctx context.Context, data *workflow.WorkData, err *workflow.PanicError) {
+	o.panics++
+}
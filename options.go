@@ -2,6 +2,13 @@ package workflow
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	gormV2 "gorm.io/gorm"
@@ -21,29 +28,227 @@ func (f applyFunc) Apply(data *WorkData) {
 	f(data)
 }
 
+var (
+	gormTxKey      = NewKey[*gormV2.DB](GormDBKey)
+	gormTxKeys     = map[string]*Key[*gormV2.DB]{GormDBKey: gormTxKey}
+	gormTxKeysLock sync.Mutex
+)
+
+// gormTxKeyNamed returns the key of the transaction named name, the name is
+// the key of the transaction in the WorkData context.
+func gormTxKeyNamed(name string) *Key[*gormV2.DB] {
+	gormTxKeysLock.Lock()
+	defer gormTxKeysLock.Unlock()
+	key, ok := gormTxKeys[name]
+	if !ok {
+		key = NewKey[*gormV2.DB](name)
+		gormTxKeys[name] = key
+	}
+	return key
+}
+
+// setGormTx stores tx under key. The default transaction is also stored
+// under the GormDBKey string, so data.Get(GormDBKey) keeps returning it.
+func setGormTx(data *WorkData, key *Key[*gormV2.DB], tx *gormV2.DB) {
+	data.setOwned(key, tx)
+	if key == gormTxKey {
+		data.setOwned(GormDBKey, tx)
+	}
+}
+
 func MustGetGormTx(data *WorkData) *gormV2.DB {
-	return data.MustGet(GormDBKey).(*gormV2.DB)
+	return gormTxKey.MustGet(data)
+}
+
+func GetGormTx(data *WorkData) (*gormV2.DB, error) {
+	return gormTxKey.Get(data)
+}
+
+func MustGetGormTxNamed(data *WorkData, name string) *gormV2.DB {
+	return gormTxKeyNamed(name).MustGet(data)
+}
+
+func GetGormTxNamed(data *WorkData, name string) (*gormV2.DB, error) {
+	return gormTxKeyNamed(name).Get(data)
+}
+
+type GormTxOptions struct {
+	// TxOptions sets the isolation level and read-only mode. Under
+	// sql.LevelSerializable the workflow is retried from Begin on
+	// serialization failures, unless a run retry policy is already set.
+	TxOptions *sql.TxOptions
+	// Session configures the transaction session, e.g. SkipHooks or
+	// PrepareStmt.
+	Session *gormV2.Session
+}
+
+const serializableAttempts = 3
+
+func (opts *GormTxOptions) apply(data *WorkData) {
+	if opts.TxOptions != nil && opts.TxOptions.Isolation == sql.LevelSerializable && data.runRetry == nil {
+		WithGormV2Retry(serializableAttempts).Apply(data)
+	}
+}
+
+// begin begins a transaction of db carrying the values of ctx. Its
+// cancellation is left to the workflow, database/sql would otherwise roll
+// back the transaction as soon as the Begin phase ends.
+func (opts *GormTxOptions) begin(ctx context.Context, db *gormV2.DB) (*gormV2.DB, error) {
+	session := gormV2.Session{}
+	if opts.Session != nil {
+		session = *opts.Session
+	}
+	if session.Context == nil {
+		session.Context = detach(ctx)
+	}
+
+	var txOpts []*sql.TxOptions
+	if opts.TxOptions != nil {
+		txOpts = append(txOpts, opts.TxOptions)
+	}
+	tx := db.Session(&session).Begin(txOpts...)
+	return tx, tx.Error
 }
 
+// WithGormV2 runs the workflow in a gorm transaction. In a sub workflow of a
+// workflow which already holds one, a savepoint is used instead.
 func WithGormV2(db *gormV2.DB) Options {
+	return WithGormV2Named(GormDBKey, db, nil)
+}
+
+// WithGormV2Named runs the workflow in a gorm transaction named name, so a
+// workflow can hold transactions of several databases. The transactions are
+// committed in the order they are registered, when one fails to commit the
+// remaining ones are rolled back and a PartialCommitError reports the
+// transactions which were already committed.
+func WithGormV2Named(name string, db *gormV2.DB, opts *GormTxOptions) Options {
+	if opts == nil {
+		opts = &GormTxOptions{}
+	}
+	key := gormTxKeyNamed(name)
 	return applyFunc(func(data *WorkData) {
+		opts.apply(data)
 		data.workBegin.Add(func(ctx context.Context, data *WorkData) error {
-			tx := db.Begin()
-			if tx.Error != nil {
-				return tx.Error
+			if tx, ok := parentGormTx(data, key); ok {
+				sp := fmt.Sprintf("workflow_sp_%d", atomic.AddUint64(&savepointSeq, 1))
+				if err := tx.SavePoint(sp).Error; err != nil {
+					return err
+				}
+				data.setLocal(gormSavepointKey{name: name}, sp)
+				data.pushCompensation(name, func(ctx context.Context, data *WorkData) error {
+					return tx.RollbackTo(sp).Error
+				})
+				return nil
+			}
+
+			tx, err := opts.begin(ctx, db)
+			if err != nil {
+				return err
 			}
-			data.Set(GormDBKey, tx)
+			setGormTx(data, key, tx)
+			state := &txState{}
+			data.setLocal(gormTxStateKey{name: name}, state)
+			// the compensation of a committed sub workflow runs on its parent,
+			// so the committed state is read from state, not from the locals.
+			data.pushCompensation(name, func(ctx context.Context, data *WorkData) error {
+				if state.committed.Load() {
+					return fmt.Errorf("gorm transaction %q: %w", name, ErrAlreadyCommitted)
+				}
+				// a transaction which failed to commit is already rolled back.
+				if err := tx.Rollback().Error; err != nil && !errors.Is(err, sql.ErrTxDone) {
+					return err
+				}
+				return nil
+			})
 			return nil
 		})
 		data.workCommit.Add(func(ctx context.Context, data *WorkData) error {
-			return MustGetGormTx(data).Commit().Error
-		})
-		data.workRollback.Add(func(ctx context.Context, data *WorkData) error {
-			return MustGetGormTx(data).Rollback().Error
+			if _, ok := data.getLocal(gormSavepointKey{name: name}); ok {
+				return nil
+			}
+			// a recovered workflow lost its transaction with the process.
+			tx, err := key.Get(data)
+			if err != nil {
+				return err
+			}
+			if err := tx.Commit().Error; err != nil {
+				if committed := gormCommittedNames(data); len(committed) > 0 {
+					return &PartialCommitError{Committed: committed, Failed: name, Err: err}
+				}
+				return err
+			}
+			data.setLocal(gormCommittedKey{}, append(gormCommittedNames(data), name))
+			if state, ok := data.getLocal(gormTxStateKey{name: name}); ok {
+				state.(*txState).committed.Store(true)
+			}
+			return nil
 		})
 	})
 }
 
+var ErrAlreadyCommitted = errors.New("transaction already committed")
+
+// PartialCommitError is returned when a transaction fails to commit after
+// other transactions of the workflow were committed, their changes are not
+// rolled back.
+type PartialCommitError struct {
+	Committed []string
+	Failed    string
+	Err       error
+}
+
+func (e *PartialCommitError) Error() string {
+	return fmt.Sprintf("commit transaction %q: %v, already committed: %s", e.Failed, e.Err, strings.Join(e.Committed, ", "))
+}
+
+func (e *PartialCommitError) Unwrap() error {
+	return e.Err
+}
+
+var savepointSeq uint64
+
+type (
+	gormSavepointKey struct{ name string }
+	gormTxStateKey   struct{ name string }
+	gormCommittedKey struct{}
+)
+
+func gormCommittedNames(data *WorkData) []string {
+	names, _ := data.getLocal(gormCommittedKey{})
+	committed, _ := names.([]string)
+	return committed
+}
+
+func parentGormTx(data *WorkData, key *Key[*gormV2.DB]) (*gormV2.DB, bool) {
+	if data.Parent() == nil {
+		return nil, false
+	}
+	tx, err := key.Get(data.Parent())
+	return tx, err == nil
+}
+
+// WithGormV2Retry restarts the workflow from Begin when the gorm transaction
+// fails with a serialization failure or a deadlock.
+func WithGormV2Retry(maxAttempts int) Options {
+	return WithRunRetry(RetryPolicy{
+		MaxAttempts: maxAttempts,
+		Backoff:     ExponentialBackoff(10*time.Millisecond, time.Second, 0.2),
+		Retryable:   IsSerializationFailure,
+	})
+}
+
+func IsSerializationFailure(err error) bool {
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		return state.SQLState() == "40001" || state.SQLState() == "40P01"
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "SQLSTATE 40001") ||
+		strings.Contains(msg, "could not serialize access") ||
+		strings.Contains(msg, "Deadlock found")
+}
+
 func WithBegin(f Event) Options {
 	return applyFunc(func(data *WorkData) {
 		data.workBegin.Add(f)
@@ -109,7 +314,5 @@ func WithInterface(param interface{}) Options {
 }
 
 func WithLogger(logger *logrus.Entry) Options {
-	return applyFunc(func(data *WorkData) {
-		data.Logger = logger.WithField("from", "workflow")
-	})
+	return WithLog(Logrus(logger))
 }
@@ -0,0 +1,227 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	gormV2 "gorm.io/gorm"
+)
+
+// Message is an event written to the outbox by WorkData.Publish. Messages are
+// delivered at least once, consumers can use ID to drop duplicates.
+type Message struct {
+	ID         uint64
+	Topic      string
+	Payload    []byte
+	WorkflowID string
+	CreatedAt  time.Time
+}
+
+type outboxMessage struct {
+	ID          uint64 `gorm:"primaryKey;autoIncrement"`
+	Topic       string `gorm:"size:255"`
+	Payload     []byte
+	WorkflowID  string `gorm:"size:64"`
+	CreatedAt   time.Time
+	PublishedAt *time.Time `gorm:"index"`
+	ParkedAt    *time.Time `gorm:"index"`
+	Attempts    int
+	LastError   string `gorm:"type:text"`
+}
+
+func (outboxMessage) TableName() string {
+	return "workflow_outbox"
+}
+
+func (m outboxMessage) message() Message {
+	return Message{
+		ID:         m.ID,
+		Topic:      m.Topic,
+		Payload:    m.Payload,
+		WorkflowID: m.WorkflowID,
+		CreatedAt:  m.CreatedAt,
+	}
+}
+
+// Publish writes a message to the outbox table in the transaction of
+// WithGormV2, so it is only relayed if the workflow commits.
+func (d *WorkData) Publish(topic string, payload []byte) error {
+	return d.PublishNamed(GormDBKey, topic, payload)
+}
+
+// PublishNamed is Publish in the transaction named name, see WithGormV2Named.
+func (d *WorkData) PublishNamed(name string, topic string, payload []byte) error {
+	tx, err := GetGormTxNamed(d, name)
+	if err != nil {
+		return fmt.Errorf("publish to %q: %w", topic, err)
+	}
+	return tx.Create(&outboxMessage{
+		Topic:      topic,
+		Payload:    payload,
+		WorkflowID: d.id,
+		CreatedAt:  time.Now(),
+	}).Error
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+type RelayConfig struct {
+	// Interval between two polls of the outbox, one second by default.
+	Interval time.Duration
+	// BatchSize is the number of messages read by a poll, 100 by default.
+	BatchSize int
+	// MaxAttempts parks a message which failed to be published MaxAttempts
+	// times: the relay skips it, so the messages after it are no longer held
+	// up. Zero retries a message until it is published.
+	MaxAttempts int
+	// Logger logs the failures of Run and the parked messages, the logrus
+	// standard logger by default.
+	Logger Logger
+}
+
+// Relay polls the outbox and hands the messages to a Publisher in the order
+// they were written. A message is marked published only after its Publisher
+// returned, so it may be published again if the relay crashes in between.
+type Relay struct {
+	db        *gormV2.DB
+	publisher Publisher
+	config    RelayConfig
+}
+
+func NewRelay(db *gormV2.DB, publisher Publisher, config RelayConfig) *Relay {
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.Logger == nil {
+		config.Logger = Logrus(logrus.StandardLogger().WithField("from", "workflow"))
+	}
+	return &Relay{db: db, publisher: publisher, config: config}
+}
+
+func (r *Relay) AutoMigrate() error {
+	return r.db.AutoMigrate(&outboxMessage{})
+}
+
+// RelayOnce publishes the pending messages of one batch and returns how many
+// were published or parked. It stops at the first message which fails to be
+// published and is not parked, so the order is kept.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	db := r.db.WithContext(ctx)
+
+	var rows []outboxMessage
+	if err := db.Where("published_at IS NULL AND parked_at IS NULL").Order("id").Limit(r.config.BatchSize).Find(&rows).Error; err != nil {
+		return 0, err
+	}
+
+	for i, row := range rows {
+		err := r.publisher.Publish(ctx, row.message())
+		if err != nil {
+			attempts := row.Attempts + 1
+			updates := map[string]interface{}{
+				"attempts":   attempts,
+				"last_error": err.Error(),
+			}
+			parked := r.config.MaxAttempts > 0 && attempts >= r.config.MaxAttempts
+			if parked {
+				updates["parked_at"] = time.Now()
+			}
+			if ue := db.Model(&row).Updates(updates).Error; ue != nil {
+				return i, ue
+			}
+			if !parked {
+				return i, fmt.Errorf("publish message %d: %w", row.ID, err)
+			}
+			r.config.Logger.WithError(err).WithField("message", row.ID).WithField("topic", row.Topic).Error("outbox message parked")
+			continue
+		}
+
+		if err := db.Model(&row).Update("published_at", time.Now()).Error; err != nil {
+			return i, err
+		}
+	}
+	return len(rows), nil
+}
+
+// Parked returns the messages parked after MaxAttempts failures.
+func (r *Relay) Parked(ctx context.Context) ([]Message, error) {
+	var rows []outboxMessage
+	if err := r.db.WithContext(ctx).Where("parked_at IS NOT NULL").Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	messages := make([]Message, 0, len(rows))
+	for _, row := range rows {
+		messages = append(messages, row.message())
+	}
+	return messages, nil
+}
+
+// Unpark hands the parked messages ids back to the relay with their attempts
+// reset.
+func (r *Relay) Unpark(ctx context.Context, ids ...uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Model(&outboxMessage{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+		"parked_at": nil,
+		"attempts":  0,
+	}).Error
+}
+
+// Run relays the messages until ctx is done. Failures are retried at the
+// next poll.
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := r.RelayOnce(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					r.config.Logger.WithError(err).Warn("relay outbox")
+				}
+				break
+			}
+			if n < r.config.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// MemoryPublisher keeps the published messages in memory.
+type MemoryPublisher struct {
+	mux      sync.Mutex
+	messages []Message
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, msg Message) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+func (p *MemoryPublisher) Messages() []Message {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return append([]Message(nil), p.messages...)
+}
@@ -0,0 +1,180 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	gormV2 "gorm.io/gorm"
+)
+
+type failingPublisher struct {
+	*workflow.MemoryPublisher
+	fail int
+}
+
+func (p *failingPublisher) Publish(ctx context.Context, msg workflow.Message) error {
+	if p.fail > 0 {
+		p.fail--
+		return errors.New("broker down")
+	}
+	return p.MemoryPublisher.Publish(ctx, msg)
+}
+
+var _ = Describe("Outbox", func() {
+	var (
+		db        *gormV2.DB
+		publisher *workflow.MemoryPublisher
+		relay     *workflow.Relay
+	)
+
+	BeforeEach(func() {
+		db = openTestDB()
+		publisher = workflow.NewMemoryPublisher()
+		relay = workflow.NewRelay(db, publisher, workflow.RelayConfig{BatchSize: 2, Interval: time.Millisecond})
+		Expect(relay.AutoMigrate()).Should(BeNil())
+	})
+
+	topics := func(messages []workflow.Message) []string {
+		var topics []string
+		for _, msg := range messages {
+			topics = append(topics, msg.Topic)
+		}
+		return topics
+	}
+
+	It("relays the messages of committed workflows", func() {
+		data, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			if err := data.Publish("order.created", []byte(`{"id":1}`)); err != nil {
+				return err
+			}
+			return data.Publish("order.paid", []byte(`{"id":1}`))
+		}, workflow.WithGormV2(db))
+		Expect(err).Should(BeNil())
+		Expect(publisher.Messages()).To(BeEmpty())
+
+		n, err := relay.RelayOnce(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(n).To(Equal(2))
+
+		messages := publisher.Messages()
+		Expect(topics(messages)).To(Equal([]string{"order.created", "order.paid"}))
+		Expect(messages[0].Payload).To(Equal([]byte(`{"id":1}`)))
+		Expect(messages[0].WorkflowID).To(Equal(data.ID()))
+
+		n, err = relay.RelayOnce(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(n).To(BeZero())
+		Expect(publisher.Messages()).To(HaveLen(2))
+	})
+
+	It("drops the messages of rolled back workflows", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			if err := data.Publish("order.created", nil); err != nil {
+				return err
+			}
+			return errors.New("work")
+		}, workflow.WithGormV2(db))
+		Expect(err).ShouldNot(BeNil())
+
+		n, err := relay.RelayOnce(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(n).To(BeZero())
+	})
+
+	It("requires the gorm transaction", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			return data.Publish("order.created", nil)
+		})
+		Expect(errors.Is(err, workflow.ErrKeyNotFound)).To(BeTrue())
+	})
+
+	It("publishes again the messages which failed", func() {
+		failing := &failingPublisher{MemoryPublisher: workflow.NewMemoryPublisher(), fail: 1}
+		relay = workflow.NewRelay(db, failing, workflow.RelayConfig{})
+
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			if err := data.Publish("first", nil); err != nil {
+				return err
+			}
+			return data.Publish("second", nil)
+		}, workflow.WithGormV2(db))
+		Expect(err).Should(BeNil())
+
+		n, err := relay.RelayOnce(context.Background())
+		Expect(err).Should(MatchError(ContainSubstring("broker down")))
+		Expect(n).To(BeZero())
+		Expect(failing.Messages()).To(BeEmpty())
+
+		n, err = relay.RelayOnce(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(n).To(Equal(2))
+		Expect(topics(failing.Messages())).To(Equal([]string{"first", "second"}))
+	})
+
+	It("parks the messages which failed too many times", func() {
+		failing := &failingPublisher{MemoryPublisher: workflow.NewMemoryPublisher(), fail: 2}
+		relay = workflow.NewRelay(db, failing, workflow.RelayConfig{MaxAttempts: 2})
+
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			if err := data.Publish("poison", nil); err != nil {
+				return err
+			}
+			return data.Publish("next", nil)
+		}, workflow.WithGormV2(db))
+		Expect(err).Should(BeNil())
+
+		_, err = relay.RelayOnce(context.Background())
+		Expect(err).Should(MatchError(ContainSubstring("broker down")))
+
+		n, err := relay.RelayOnce(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(n).To(Equal(2))
+		Expect(topics(failing.Messages())).To(Equal([]string{"next"}))
+
+		parked, err := relay.Parked(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(topics(parked)).To(Equal([]string{"poison"}))
+
+		Expect(relay.Unpark(context.Background(), parked[0].ID)).Should(BeNil())
+		n, err = relay.RelayOnce(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(n).To(Equal(1))
+		Expect(topics(failing.Messages())).To(Equal([]string{"next", "poison"}))
+	})
+
+	It("publishes in a named transaction", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			return data.PublishNamed("orders", "order.created", nil)
+		}, workflow.WithGormV2Named("orders", db, nil))
+		Expect(err).Should(BeNil())
+
+		_, err = relay.RelayOnce(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(topics(publisher.Messages())).To(Equal([]string{"order.created"}))
+	})
+
+	It("relays in batches until the context is done", func() {
+		for i := 0; i < 5; i++ {
+			_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+				return data.Publish("order.created", nil)
+			}, workflow.WithGormV2(db))
+			Expect(err).Should(BeNil())
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- relay.Run(ctx)
+		}()
+
+		Eventually(func() int {
+			return len(publisher.Messages())
+		}).Should(Equal(5))
+		cancel()
+		Expect(<-done).Should(MatchError(context.Canceled))
+	})
+})
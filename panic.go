@@ -0,0 +1,26 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned in place of a panic raised by an Event.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+func safeCall(ctx context.Context, f Event, data *WorkData) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = &PanicError{Value: e, Stack: debug.Stack()}
+		}
+	}()
+	return f(ctx, data)
+}
@@ -0,0 +1,106 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Panic", func() {
+	nop := func(ctx context.Context, data *workflow.WorkData) error {
+		return nil
+	}
+	boom := func(ctx context.Context, data *workflow.WorkData) error {
+		panic("boom")
+	}
+
+	It("recovers a panic in begin and releases begun resources", func() {
+		released := false
+		data, err := workflow.StartWorkFlow(
+			nop,
+			workflow.WithBegin(func(ctx context.Context, data *workflow.WorkData) error {
+				return data.Step(ctx, "tx", nop, func(ctx context.Context, data *workflow.WorkData) error {
+					released = true
+					return nil
+				})
+			}),
+			workflow.WithBegin(boom),
+		)
+		var perr *workflow.PanicError
+		Expect(errors.As(err, &perr)).To(BeTrue())
+		Expect(perr.Value).To(Equal("boom"))
+		Expect(perr.Stack).NotTo(BeEmpty())
+		Expect(released).To(BeTrue())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomePanicked))
+	})
+
+	It("recovers a panic in commit and rolls back", func() {
+		rolledBack := false
+		data, err := workflow.StartWorkFlow(
+			nop,
+			workflow.WithCommit(boom),
+			workflow.WithRollback(func(ctx context.Context, data *workflow.WorkData) error {
+				rolledBack = true
+				return nil
+			}),
+		)
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.Phase).To(Equal(workflow.PhaseCommit))
+		Expect(rolledBack).To(BeTrue())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomePanicked))
+	})
+
+	It("recovers a panic in rollback", func() {
+		data, err := workflow.StartWorkFlow(
+			boom,
+			workflow.WithRollback(boom),
+		)
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.Phase).To(Equal(workflow.PhaseWork))
+		Expect(werr.RollbackErrors).To(HaveLen(1))
+		var perr *workflow.PanicError
+		Expect(errors.As(werr.RollbackErrors[0], &perr)).To(BeTrue())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRollbackFailed))
+	})
+
+	It("recovers a panic in finish", func() {
+		data, err := workflow.StartWorkFlow(
+			nop,
+			workflow.WithFinish(boom),
+		)
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.Phase).To(Equal(workflow.PhaseFinish))
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeCommitted))
+	})
+	It("recovers a panic in a compensation and runs the other ones", func() {
+		released := false
+		data, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				if err := data.Step(ctx, "first", nop, func(ctx context.Context, data *workflow.WorkData) error {
+					released = true
+					return nil
+				}); err != nil {
+					return err
+				}
+				if err := data.Step(ctx, "second", nop, boom); err != nil {
+					return err
+				}
+				return errors.New("work")
+			},
+		)
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.RollbackErrors).To(HaveLen(1))
+		var perr *workflow.PanicError
+		Expect(errors.As(werr.RollbackErrors[0], &perr)).To(BeTrue())
+		Expect(perr.Value).To(Equal("boom"))
+		Expect(released).To(BeTrue())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRollbackFailed))
+	})
+})
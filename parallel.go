@@ -0,0 +1,97 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type ParallelConfig struct {
+	// Limit is the maximum number of events running at the same time,
+	// zero means no limit.
+	Limit int
+	// CollectAll runs every event even when one fails, instead of
+	// cancelling the others on the first failure.
+	CollectAll bool
+}
+
+type ParallelError struct {
+	Errors []error
+}
+
+func (e *ParallelError) Error() string {
+	return fmt.Sprintf("parallel events failed: %v", joinErrors(e.Errors))
+}
+
+func (e *ParallelError) Unwrap() []error {
+	return e.Errors
+}
+
+func Parallel(events ...Event) Event {
+	return ParallelWithConfig(ParallelConfig{}, events...)
+}
+
+// ParallelWithConfig runs the events concurrently against the same WorkData.
+// Siblings are cancelled through their context when the workflow is aborted.
+func ParallelWithConfig(config ParallelConfig, events ...Event) Event {
+	return func(ctx context.Context, data *WorkData) error {
+		parent := ctx
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		go func() {
+			select {
+			case <-data.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		var sem chan struct{}
+		if config.Limit > 0 {
+			sem = make(chan struct{}, config.Limit)
+		}
+
+		errs := make([]error, len(events))
+		wg := sync.WaitGroup{}
+		for i, f := range events {
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					continue
+				}
+			}
+
+			wg.Add(1)
+			go func(i int, f Event) {
+				defer wg.Done()
+				if sem != nil {
+					defer func() { <-sem }()
+				}
+
+				if err := safeCall(ctx, f, data); err != nil {
+					errs[i] = fmt.Errorf("event #%d (%s): %w", i, handlerName(f), err)
+					if !config.CollectAll {
+						cancel()
+					}
+				}
+			}(i, f)
+		}
+		wg.Wait()
+
+		var failed []error
+		for _, err := range errs {
+			// siblings cancelled because of another failure are not reported.
+			if err == nil || (errors.Is(err, context.Canceled) && parent.Err() == nil) {
+				continue
+			}
+			failed = append(failed, err)
+		}
+		if len(failed) == 0 {
+			return nil
+		}
+		return &ParallelError{Errors: failed}
+	}
+}
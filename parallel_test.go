@@ -0,0 +1,133 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parallel", func() {
+	errService := errors.New("service")
+	errOther := errors.New("other")
+
+	sleep := func(calls *int32) workflow.Event {
+		return func(ctx context.Context, data *workflow.WorkData) error {
+			atomic.AddInt32(calls, 1)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(20 * time.Millisecond):
+				return nil
+			}
+		}
+	}
+	fail := func(ctx context.Context, data *workflow.WorkData) error {
+		return errService
+	}
+	waitCancel := func(ctx context.Context, data *workflow.WorkData) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	It("runs every event concurrently", func() {
+		// every event waits for the others to start, so they only return
+		// without an error if all of them ran at the same time.
+		var started sync.WaitGroup
+		started.Add(3)
+		all := make(chan struct{})
+		go func() {
+			started.Wait()
+			close(all)
+		}()
+		event := func(ctx context.Context, data *workflow.WorkData) error {
+			started.Done()
+			select {
+			case <-all:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("events did not overlap")
+			}
+		}
+
+		_, err := workflow.StartWorkFlow(workflow.Parallel(event, event, event))
+		Expect(err).Should(BeNil())
+	})
+
+	It("limits the concurrency", func() {
+		var running, max int32
+		event := func(ctx context.Context, data *workflow.WorkData) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}
+		_, err := workflow.StartWorkFlow(workflow.ParallelWithConfig(
+			workflow.ParallelConfig{Limit: 2},
+			event, event, event, event, event,
+		))
+		Expect(err).Should(BeNil())
+		Expect(atomic.LoadInt32(&max)).To(BeEquivalentTo(2))
+	})
+
+	It("cancels siblings on the first failure and rolls back", func() {
+		rolledBack := false
+		data, err := workflow.StartWorkFlow(
+			workflow.Parallel(waitCancel, fail, waitCancel),
+			workflow.WithRollback(func(ctx context.Context, data *workflow.WorkData) error {
+				rolledBack = true
+				return nil
+			}),
+		)
+		var perr *workflow.ParallelError
+		Expect(errors.As(err, &perr)).To(BeTrue())
+		Expect(perr.Errors).To(HaveLen(1))
+		Expect(errors.Is(err, errService)).To(BeTrue())
+		Expect(rolledBack).To(BeTrue())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRolledBack))
+	})
+
+	It("collects every error", func() {
+		var calls int32
+		_, err := workflow.StartWorkFlow(workflow.ParallelWithConfig(
+			workflow.ParallelConfig{CollectAll: true},
+			fail, sleep(&calls), fail,
+		))
+		var perr *workflow.ParallelError
+		Expect(errors.As(err, &perr)).To(BeTrue())
+		Expect(perr.Errors).To(HaveLen(2))
+		Expect(calls).To(BeEquivalentTo(1))
+
+		_, err = workflow.StartWorkFlow(workflow.ParallelWithConfig(
+			workflow.ParallelConfig{CollectAll: true},
+			sleep(&calls), func(ctx context.Context, data *workflow.WorkData) error {
+				return errOther
+			}, fail,
+		))
+		Expect(errors.Is(err, errOther)).To(BeTrue())
+		Expect(errors.Is(err, errService)).To(BeTrue())
+	})
+
+	It("cancels siblings when one aborts", func() {
+		data, err := workflow.StartWorkFlow(workflow.Parallel(
+			waitCancel,
+			func(ctx context.Context, data *workflow.WorkData) error {
+				data.Abort()
+				return nil
+			},
+		))
+		Expect(err).Should(BeNil())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeAborted))
+	})
+})
@@ -0,0 +1,31 @@
+package workflow
+
+type Phase int
+
+const (
+	PhaseBegin Phase = iota
+	PhaseWork
+	PhaseBeforeCommit
+	PhaseCommit
+	PhaseRollback
+	PhaseFinish
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseBegin:
+		return "begin"
+	case PhaseWork:
+		return "work"
+	case PhaseBeforeCommit:
+		return "beforeCommit"
+	case PhaseCommit:
+		return "commit"
+	case PhaseRollback:
+		return "rollback"
+	case PhaseFinish:
+		return "finish"
+	default:
+		return "unknown"
+	}
+}
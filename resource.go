@@ -0,0 +1,265 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Resource takes part in the two-phase commit of a workflow. Resources are
+// begun in Begin, prepared in BeforeCommit and only committed in Commit if
+// all of them were prepared, otherwise they are rolled back.
+//
+// A prepared resource is in doubt until it is committed or rolled back. In
+// doubt resources are persisted with the workflow state, so Recover commits
+// them if the workflow had decided to commit and rolls them back otherwise.
+// Commit and Rollback must therefore work on a WorkData restored from the
+// store and be idempotent.
+type Resource interface {
+	Name() string
+	Begin(ctx context.Context, data *WorkData) error
+	Prepare(ctx context.Context, data *WorkData) error
+	Commit(ctx context.Context, data *WorkData) error
+	Rollback(ctx context.Context, data *WorkData) error
+}
+
+// DurableResource is implemented by resources which tell whether their
+// prepare survives a crash of the process, the others are durable. A resource
+// which is not durable is lost with the process, so it is never in doubt: it
+// is committed before the durable resources and its failure rolls the
+// workflow back.
+type DurableResource interface {
+	Resource
+	Durable() bool
+}
+
+func durable(r Resource) bool {
+	if d, ok := r.(DurableResource); ok {
+		return d.Durable()
+	}
+	return true
+}
+
+type resourceState int
+
+const (
+	resourceBegun resourceState = iota + 1
+	resourcePrepared
+	resourceCommitted
+	resourceRolledBack
+	// resourceReady is a prepared resource which is not durable.
+	resourceReady
+)
+
+var ErrInDoubt = errors.New("resource in doubt")
+
+// InDoubtError is the error of a workflow which decided to commit while some
+// of its prepared resources failed to commit. They stay in doubt until they
+// are committed by Recover, the workflow is still committed.
+type InDoubtError struct {
+	Resources []string
+	Errors    []error
+}
+
+func (e *InDoubtError) Error() string {
+	return fmt.Sprintf("resources in doubt %s: %s", strings.Join(e.Resources, ", "), joinErrors(e.Errors))
+}
+
+func (e *InDoubtError) Unwrap() []error {
+	return append([]error{ErrInDoubt}, e.Errors...)
+}
+
+func WithResource(r Resource) Options {
+	return applyFunc(func(data *WorkData) {
+		data.resources = append(data.resources, r)
+		data.workBegin.Add(func(ctx context.Context, data *WorkData) error {
+			if err := r.Begin(ctx, data); err != nil {
+				return err
+			}
+			data.setResourceState(r.Name(), resourceBegun)
+			data.pushCompensation(r.Name(), func(ctx context.Context, data *WorkData) error {
+				return data.rollbackResource(ctx, r)
+			})
+			return nil
+		})
+		if len(data.resources) == 1 {
+			data.workBeforeCommit.Add(prepareResources)
+			data.workCommit.Add(commitResources)
+		}
+	})
+}
+
+func prepareResources(ctx context.Context, data *WorkData) error {
+	for _, r := range data.resources {
+		if data.resourceState(r.Name()) != resourceBegun {
+			continue
+		}
+		if err := r.Prepare(ctx, data); err != nil {
+			return fmt.Errorf("prepare resource %q: %w", r.Name(), err)
+		}
+		if !durable(r) {
+			data.setResourceState(r.Name(), resourceReady)
+			continue
+		}
+		data.setResourceState(r.Name(), resourcePrepared)
+		if err := data.persist(ctx, data.phase, data.Progress(data.phase)); err != nil {
+			return fmt.Errorf("persist workflow state: %w", err)
+		}
+	}
+	return nil
+}
+
+// commitResources commits the prepared resources. Once all the resources are
+// prepared the workflow is committed, a resource failing to commit stays in
+// doubt and is committed again by Recover. The run reports it with an
+// InDoubtError, the other resources are still committed.
+//
+// The resources which are not durable are committed first, while the durable
+// ones can still be rolled back. If one fails, the workflow is rolled back
+// and a PartialCommitError reports the ones already committed.
+func commitResources(ctx context.Context, data *WorkData) error {
+	var committed []string
+	for _, r := range data.resources {
+		if data.resourceState(r.Name()) != resourceReady {
+			continue
+		}
+		if err := r.Commit(ctx, data); err != nil {
+			if len(committed) == 0 {
+				return fmt.Errorf("commit resource %q: %w", r.Name(), err)
+			}
+			return &PartialCommitError{Committed: committed, Failed: r.Name(), Err: err}
+		}
+		data.setResourceState(r.Name(), resourceCommitted)
+		committed = append(committed, r.Name())
+	}
+
+	data.resourcesLocker.Lock()
+	data.resourcesCommitting = true
+	data.resourcesLocker.Unlock()
+
+	for _, r := range data.resources {
+		if data.resourceState(r.Name()) != resourcePrepared {
+			continue
+		}
+		if err := r.Commit(ctx, data); err != nil {
+			data.Logger.WithError(err).WithField("resource", r.Name()).Error("resource in doubt")
+			data.setResourceError(r.Name(), err)
+			continue
+		}
+		data.setResourceState(r.Name(), resourceCommitted)
+		if err := data.persist(ctx, data.phase, data.Progress(data.phase)); err != nil {
+			data.Logger.WithError(err).Error("persist workflow state")
+		}
+	}
+	return nil
+}
+
+func (d *WorkData) rollbackResource(ctx context.Context, r Resource) error {
+	d.resourcesLocker.Lock()
+	committing := d.resourcesCommitting
+	d.resourcesLocker.Unlock()
+
+	switch state := d.resourceState(r.Name()); {
+	case state == resourceCommitted:
+		return fmt.Errorf("resource %q: %w", r.Name(), ErrAlreadyCommitted)
+	case state == resourcePrepared && committing:
+		return fmt.Errorf("resource %q: %w", r.Name(), ErrInDoubt)
+	}
+
+	if err := r.Rollback(ctx, d); err != nil {
+		return err
+	}
+	d.setResourceState(r.Name(), resourceRolledBack)
+	return nil
+}
+
+func (d *WorkData) resourceState(name string) resourceState {
+	d.resourcesLocker.Lock()
+	defer d.resourcesLocker.Unlock()
+	return d.resourceStates[name]
+}
+
+func (d *WorkData) setResourceState(name string, state resourceState) {
+	d.resourcesLocker.Lock()
+	defer d.resourcesLocker.Unlock()
+	d.resourceStates[name] = state
+	delete(d.resourceErrors, name)
+}
+
+func (d *WorkData) setResourceError(name string, err error) {
+	d.resourcesLocker.Lock()
+	defer d.resourcesLocker.Unlock()
+	d.resourceErrors[name] = err
+}
+
+// InDoubt returns the names of the resources which are prepared but neither
+// committed nor rolled back.
+func (d *WorkData) InDoubt() []string {
+	d.resourcesLocker.Lock()
+	defer d.resourcesLocker.Unlock()
+
+	var names []string
+	for _, r := range d.resources {
+		if d.resourceStates[r.Name()] == resourcePrepared {
+			names = append(names, r.Name())
+		}
+	}
+	return names
+}
+
+// withInDoubt adds the InDoubtError of the resources left in doubt to err, the
+// result of a workflow which decided to commit or roll back.
+func (d *WorkData) withInDoubt(err error) error {
+	names := d.InDoubt()
+	if len(names) == 0 {
+		return err
+	}
+
+	ierr := &InDoubtError{Resources: names}
+	d.resourcesLocker.Lock()
+	for _, name := range names {
+		if rerr := d.resourceErrors[name]; rerr != nil {
+			ierr.Errors = append(ierr.Errors, fmt.Errorf("resource %q: %w", name, rerr))
+		}
+	}
+	d.resourcesLocker.Unlock()
+
+	var werr *WorkflowError
+	if errors.As(err, &werr) && werr.Cause == nil {
+		werr.Cause = ierr
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	return ierr
+}
+
+// resolveInDoubt commits or rolls back the in doubt resources of a recovered
+// workflow.
+func (d *WorkData) resolveInDoubt(ctx context.Context, commit bool) {
+	for _, r := range d.resources {
+		if d.resourceState(r.Name()) != resourcePrepared {
+			continue
+		}
+
+		logger := d.Logger.WithField("resource", r.Name())
+		if commit {
+			if err := r.Commit(ctx, d); err != nil {
+				logger.WithError(err).Error("resource in doubt")
+				d.setResourceError(r.Name(), err)
+				continue
+			}
+			d.setResourceState(r.Name(), resourceCommitted)
+		} else {
+			if err := r.Rollback(ctx, d); err != nil {
+				logger.WithError(err).Error("resource in doubt")
+				d.setResourceError(r.Name(), err)
+				continue
+			}
+			d.setResourceState(r.Name(), resourceRolledBack)
+		}
+		logger.Info("resolved resource in doubt")
+	}
+}
@@ -0,0 +1,234 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeResource struct {
+	name       string
+	events     *[]string
+	prepareErr error
+	commitErr  error
+}
+
+func (r *fakeResource) Name() string {
+	return r.name
+}
+
+func (r *fakeResource) Begin(ctx context.Context, data *workflow.WorkData) error {
+	*r.events = append(*r.events, "begin "+r.name)
+	return nil
+}
+
+func (r *fakeResource) Prepare(ctx context.Context, data *workflow.WorkData) error {
+	*r.events = append(*r.events, "prepare "+r.name)
+	return r.prepareErr
+}
+
+func (r *fakeResource) Commit(ctx context.Context, data *workflow.WorkData) error {
+	*r.events = append(*r.events, "commit "+r.name)
+	return r.commitErr
+}
+
+func (r *fakeResource) Rollback(ctx context.Context, data *workflow.WorkData) error {
+	*r.events = append(*r.events, "rollback "+r.name)
+	return nil
+}
+
+// volatileResource is a fakeResource whose prepare does not survive a crash.
+type volatileResource struct {
+	*fakeResource
+}
+
+func (volatileResource) Durable() bool {
+	return false
+}
+
+var _ = Describe("Resource", func() {
+	var (
+		events []string
+		a, b   *fakeResource
+	)
+
+	BeforeEach(func() {
+		events = []string{}
+		a = &fakeResource{name: "a", events: &events}
+		b = &fakeResource{name: "b", events: &events}
+	})
+
+	work := func(ctx context.Context, data *workflow.WorkData) error {
+		return nil
+	}
+
+	It("commits the resources once all of them are prepared", func() {
+		data, err := workflow.StartWorkFlow(work, workflow.WithResource(a), workflow.WithResource(b))
+		Expect(err).Should(BeNil())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeCommitted))
+		Expect(events).To(Equal([]string{"begin a", "begin b", "prepare a", "prepare b", "commit a", "commit b"}))
+		Expect(data.InDoubt()).To(BeEmpty())
+	})
+
+	It("rolls back every resource when one fails to prepare", func() {
+		b.prepareErr = errors.New("prepare")
+
+		data, err := workflow.StartWorkFlow(work, workflow.WithResource(a), workflow.WithResource(b))
+		Expect(err).Should(MatchError(ContainSubstring(`prepare resource "b": prepare`)))
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRolledBack))
+		Expect(events).To(Equal([]string{"begin a", "begin b", "prepare a", "prepare b", "rollback b", "rollback a"}))
+	})
+
+	It("rolls back the resources when the work fails", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			return errors.New("work")
+		}, workflow.WithResource(a), workflow.WithResource(b))
+		Expect(err).ShouldNot(BeNil())
+		Expect(events).To(Equal([]string{"begin a", "begin b", "rollback b", "rollback a"}))
+	})
+
+	It("keeps the resources which fail to commit in doubt", func() {
+		a.commitErr = errors.New("commit")
+		store := workflow.NewMemoryStore()
+		w := workflow.MustDefine("order", workflow.WithStore(store), workflow.WithResource(a), workflow.WithResource(b))
+
+		data, err := w.Run(context.Background(), work)
+		Expect(errors.Is(err, workflow.ErrInDoubt)).To(BeTrue())
+		var ierr *workflow.InDoubtError
+		Expect(errors.As(err, &ierr)).To(BeTrue())
+		Expect(ierr.Resources).To(Equal([]string{"a"}))
+		Expect(ierr.Errors).To(HaveLen(1))
+		Expect(ierr.Errors[0]).To(MatchError(`resource "a": commit`))
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeCommitted))
+		Expect(data.InDoubt()).To(Equal([]string{"a"}))
+		Expect(events).To(Equal([]string{"begin a", "begin b", "prepare a", "prepare b", "commit a", "commit b"}))
+
+		state, _ := store.Get(context.Background(), data.ID())
+		Expect(state.Done).To(BeFalse())
+		Expect(state.InDoubt).To(Equal([]string{"a"}))
+
+		a.commitErr = nil
+		events = events[:0]
+		recovered, err := workflow.Recover(context.Background(), store, w)
+		Expect(err).Should(BeNil())
+		Expect(recovered).To(HaveLen(1))
+		Expect(recovered[0].Outcome()).To(Equal(workflow.OutcomeCommitted))
+		Expect(recovered[0].Result().Err).Should(BeNil())
+		Expect(recovered[0].InDoubt()).To(BeEmpty())
+		Expect(events).To(Equal([]string{"commit a"}))
+
+		state, _ = store.Get(context.Background(), data.ID())
+		Expect(state.Done).To(BeTrue())
+	})
+
+	It("does not roll back resources in doubt when a later commit handler fails", func() {
+		a.commitErr = errors.New("commit")
+
+		data, err := workflow.StartWorkFlow(work,
+			workflow.WithResource(a),
+			workflow.WithCommit(func(ctx context.Context, data *workflow.WorkData) error {
+				return errors.New("later")
+			}),
+		)
+		Expect(err).ShouldNot(BeNil())
+
+		var werr *workflow.WorkflowError
+		Expect(errors.As(err, &werr)).To(BeTrue())
+		Expect(werr.Cause).To(MatchError("later"))
+		Expect(werr.RollbackErrors).To(HaveLen(1))
+		Expect(errors.Is(err, workflow.ErrInDoubt)).To(BeTrue())
+		Expect(data.InDoubt()).To(Equal([]string{"a"}))
+		Expect(events).NotTo(ContainElement("rollback a"))
+	})
+
+	It("rolls back resources prepared before a crash", func() {
+		store := workflow.NewMemoryStore()
+		w := workflow.MustDefine("order", workflow.WithStore(store), workflow.WithResource(a), workflow.WithResource(b))
+		Expect(store.Save(context.Background(), &workflow.State{
+			ID:      "crashed",
+			Name:    "order",
+			Phase:   workflow.PhaseBeforeCommit,
+			InDoubt: []string{"a"},
+		})).Should(BeNil())
+
+		recovered, err := workflow.Recover(context.Background(), store, w)
+		Expect(err).Should(BeNil())
+		Expect(recovered).To(HaveLen(1))
+		Expect(recovered[0].Outcome()).To(Equal(workflow.OutcomeRolledBack))
+		Expect(events).To(Equal([]string{"rollback a"}))
+	})
+
+	It("commits the resources which are not durable first without keeping them in doubt", func() {
+		v := volatileResource{&fakeResource{name: "v", events: &events}}
+		store := workflow.NewMemoryStore()
+
+		data, err := workflow.StartWorkFlow(work,
+			workflow.WithStore(store),
+			workflow.WithResource(a),
+			workflow.WithResource(v),
+			workflow.WithBeforeCommit(func(ctx context.Context, data *workflow.WorkData) error {
+				Expect(data.InDoubt()).To(Equal([]string{"a"}))
+				return nil
+			}),
+		)
+		Expect(err).Should(BeNil())
+		Expect(events).To(Equal([]string{"begin a", "begin v", "prepare a", "prepare v", "commit v", "commit a"}))
+
+		state, _ := store.Get(context.Background(), data.ID())
+		Expect(state.Done).To(BeTrue())
+	})
+
+	It("rolls back the durable resources when one which is not durable fails to commit", func() {
+		v := volatileResource{&fakeResource{name: "v", events: &events, commitErr: errors.New("commit")}}
+
+		data, err := workflow.StartWorkFlow(work, workflow.WithResource(a), workflow.WithResource(v))
+		Expect(err).Should(MatchError(ContainSubstring(`commit resource "v": commit`)))
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRolledBack))
+		Expect(data.InDoubt()).To(BeEmpty())
+		Expect(events).To(Equal([]string{"begin a", "begin v", "prepare a", "prepare v", "commit v", "rollback v", "rollback a"}))
+	})
+
+	It("reports the resources committed before one which is not durable fails", func() {
+		v := volatileResource{&fakeResource{name: "v", events: &events}}
+		w := volatileResource{&fakeResource{name: "w", events: &events, commitErr: errors.New("commit")}}
+
+		_, err := workflow.StartWorkFlow(work, workflow.WithResource(v), workflow.WithResource(w))
+		var perr *workflow.PartialCommitError
+		Expect(errors.As(err, &perr)).To(BeTrue())
+		Expect(perr.Committed).To(Equal([]string{"v"}))
+		Expect(perr.Failed).To(Equal("w"))
+	})
+
+	Describe("NewGormResource", func() {
+		It("commits and rolls back the transaction", func() {
+			db := openTestDB()
+			Expect(db.AutoMigrate(&testOrder{})).Should(BeNil())
+
+			create := func(id uint) workflow.Event {
+				return func(ctx context.Context, data *workflow.WorkData) error {
+					return workflow.MustGetGormTxNamed(data, "orders").Create(&testOrder{ID: id}).Error
+				}
+			}
+
+			_, err := workflow.StartWorkFlow(create(1),
+				workflow.WithGormV2Resource("orders", db, nil),
+				workflow.WithResource(a),
+			)
+			Expect(err).Should(BeNil())
+
+			b.prepareErr = errors.New("prepare")
+			_, err = workflow.StartWorkFlow(create(2),
+				workflow.WithGormV2Resource("orders", db, nil),
+				workflow.WithResource(b),
+			)
+			Expect(err).ShouldNot(BeNil())
+
+			var ids []uint
+			Expect(db.Model(&testOrder{}).Pluck("id", &ids).Error).Should(BeNil())
+			Expect(ids).To(Equal([]uint{1}))
+		})
+	})
+})
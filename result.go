@@ -0,0 +1,66 @@
+package workflow
+
+type Outcome int
+
+const (
+	OutcomeRunning Outcome = iota
+	OutcomeCommitted
+	OutcomeAborted
+	OutcomeFailed
+	OutcomeRolledBack
+	OutcomeRollbackFailed
+	OutcomePanicked
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeRunning:
+		return "running"
+	case OutcomeCommitted:
+		return "committed"
+	case OutcomeAborted:
+		return "aborted"
+	case OutcomeFailed:
+		return "failed"
+	case OutcomeRolledBack:
+		return "rolledBack"
+	case OutcomeRollbackFailed:
+		return "rollbackFailed"
+	case OutcomePanicked:
+		return "panicked"
+	default:
+		return "unknown"
+	}
+}
+
+// Result is the final state of a workflow run.
+type Result struct {
+	Outcome     Outcome
+	AbortReason error
+	Err         error
+	// Retry tells that the run is attempted again under its WithRunRetry
+	// policy, this Result is not the final one.
+	Retry bool
+}
+
+func (d *WorkData) Outcome() Outcome {
+	return d.result.Outcome
+}
+
+func (d *WorkData) AbortReason() error {
+	d.stateLocker.Lock()
+	defer d.stateLocker.Unlock()
+	return d.abortReason
+}
+
+func (d *WorkData) Result() Result {
+	return d.result
+}
+
+func (d *WorkData) setResult(outcome Outcome, err error) {
+	d.result = Result{
+		Outcome:     outcome,
+		AbortReason: d.AbortReason(),
+		Err:         err,
+	}
+}
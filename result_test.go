@@ -0,0 +1,73 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Result", func() {
+	errFailed := errors.New("failed")
+	errReason := errors.New("out of stock")
+
+	nop := func(ctx context.Context, data *workflow.WorkData) error {
+		return nil
+	}
+	fail := func(ctx context.Context, data *workflow.WorkData) error {
+		return errFailed
+	}
+	abort := func(ctx context.Context, data *workflow.WorkData) error {
+		data.AbortWithReason(errReason)
+		return nil
+	}
+
+	It("is committed", func() {
+		data, err := workflow.StartWorkFlow(nop)
+		Expect(err).Should(BeNil())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeCommitted))
+	})
+
+	It("is aborted with a reason", func() {
+		data, err := workflow.StartWorkFlow(abort)
+		Expect(err).Should(BeNil())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeAborted))
+		Expect(data.AbortReason()).To(Equal(errReason))
+		Expect(data.Result().AbortReason).To(Equal(errReason))
+	})
+
+	It("is aborted in begin", func() {
+		data, err := workflow.StartWorkFlow(nop, workflow.WithBegin(abort))
+		Expect(err).Should(BeNil())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeAborted))
+	})
+
+	It("is failed in begin", func() {
+		data, err := workflow.StartWorkFlow(nop, workflow.WithBegin(fail))
+		Expect(err).ShouldNot(BeNil())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeFailed))
+		Expect(data.Result().Err).To(Equal(err))
+	})
+
+	It("is rolled back", func() {
+		data, err := workflow.StartWorkFlow(fail)
+		Expect(err).ShouldNot(BeNil())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRolledBack))
+	})
+
+	It("is rollback failed", func() {
+		data, err := workflow.StartWorkFlow(fail, workflow.WithRollback(fail))
+		Expect(err).ShouldNot(BeNil())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRollbackFailed))
+	})
+
+	It("is panicked", func() {
+		data, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			panic("boom")
+		})
+		Expect(err).ShouldNot(BeNil())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomePanicked))
+	})
+})
@@ -0,0 +1,139 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides whether and when a failed Event is attempted again.
+// An empty Retryable retries every error except panics and context errors.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     func(attempt int) time.Duration
+	Retryable   func(err error) bool
+}
+
+func FixedBackoff(interval time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		return interval
+	}
+}
+
+func ExponentialBackoff(base, max time.Duration, jitter float64) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		if jitter > 0 {
+			d += time.Duration(rand.Float64() * jitter * float64(d))
+		}
+		return d
+	}
+}
+
+func (p RetryPolicy) allows(attempt int, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+
+	var perr *PanicError
+	if errors.As(err, &perr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// retries tells whether the ended run of d is attempted again under its run
+// retry policy.
+func (d *WorkData) retries(ctx context.Context) bool {
+	policy := d.runRetry
+	err := d.result.Err
+	return err != nil && policy != nil && restartable(d.Outcome()) && policy.allows(d.attempt, err) && ctx.Err() == nil
+}
+
+func (p RetryPolicy) wait(ctx context.Context, attempt int) bool {
+	if p.Backoff == nil {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(p.Backoff(attempt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func Retry(policy RetryPolicy, f Event) Event {
+	name := handlerName(f)
+	return func(ctx context.Context, data *WorkData) error {
+		for attempt := 1; ; attempt++ {
+			data.recordAttempt(name, attempt)
+			err := f(ctx, data)
+			if err == nil || data.IsAborted() || !policy.allows(attempt, err) {
+				return err
+			}
+
+			data.Logger.WithError(err).WithField("handler", name).WithField("attempt", attempt).Warn("retrying")
+			if !policy.wait(ctx, attempt) {
+				return err
+			}
+		}
+	}
+}
+
+func WithRetry(policy RetryPolicy) Options {
+	return WithPhaseRetry(PhaseWork, policy)
+}
+
+func WithPhaseRetry(phase Phase, policy RetryPolicy) Options {
+	return applyFunc(func(data *WorkData) {
+		data.phaseRetries[phase] = policy
+	})
+}
+
+// WithRunRetry restarts the whole workflow from Begin when it was rolled back
+// because of an error allowed by the policy.
+func WithRunRetry(policy RetryPolicy) Options {
+	return applyFunc(func(data *WorkData) {
+		data.runRetry = &policy
+	})
+}
+
+func (d *WorkData) wrapRetry(phase Phase, f Event) Event {
+	if policy, ok := d.phaseRetries[phase]; ok {
+		return Retry(policy, f)
+	}
+	return f
+}
+
+func (d *WorkData) recordAttempt(name string, attempt int) {
+	d.attemptsLocker.Lock()
+	defer d.attemptsLocker.Unlock()
+	d.attempts[name] = attempt
+}
+
+// Attempts returns how many times each retried handler was called.
+func (d *WorkData) Attempts() map[string]int {
+	d.attemptsLocker.Lock()
+	defer d.attemptsLocker.Unlock()
+
+	attempts := make(map[string]int, len(d.attempts))
+	for name, n := range d.attempts {
+		attempts[name] = n
+	}
+	return attempts
+}
+
+// Attempt returns the attempt number of the whole workflow run.
+func (d *WorkData) Attempt() int {
+	return d.attempt
+}
@@ -0,0 +1,133 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "sql error " + string(e)
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
+}
+
+var _ = Describe("Retry", func() {
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+
+	nop := func(ctx context.Context, data *workflow.WorkData) error {
+		return nil
+	}
+	failTimes := func(n int, err error, calls *int) workflow.Event {
+		return func(ctx context.Context, data *workflow.WorkData) error {
+			*calls++
+			if *calls <= n {
+				return err
+			}
+			return nil
+		}
+	}
+	policy := workflow.RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     workflow.FixedBackoff(time.Millisecond),
+		Retryable: func(err error) bool {
+			return errors.Is(err, errTransient)
+		},
+	}
+
+	It("retries a single handler", func() {
+		calls := 0
+		data, err := workflow.StartWorkFlow(
+			nop,
+			workflow.WithCommit(workflow.Retry(policy, failTimes(2, errTransient, &calls))),
+		)
+		Expect(err).Should(BeNil())
+		Expect(calls).To(Equal(3))
+		Expect(data.Attempts()).To(ContainElement(3))
+	})
+
+	It("gives up after max attempts", func() {
+		calls := 0
+		data, err := workflow.StartWorkFlow(
+			nop,
+			workflow.WithCommit(workflow.Retry(policy, failTimes(5, errTransient, &calls))),
+		)
+		Expect(err).Should(MatchError(errTransient))
+		Expect(calls).To(Equal(3))
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRolledBack))
+	})
+
+	It("does not retry errors that are not retryable", func() {
+		calls := 0
+		_, err := workflow.StartWorkFlow(
+			nop,
+			workflow.WithCommit(workflow.Retry(policy, failTimes(1, errFatal, &calls))),
+		)
+		Expect(err).Should(MatchError(errFatal))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("retries the work function", func() {
+		calls := 0
+		_, err := workflow.StartWorkFlow(
+			failTimes(2, errTransient, &calls),
+			workflow.WithRetry(policy),
+		)
+		Expect(err).Should(BeNil())
+		Expect(calls).To(Equal(3))
+	})
+
+	It("retries every handler of a phase", func() {
+		first, second := 0, 0
+		_, err := workflow.StartWorkFlow(
+			nop,
+			workflow.WithPhaseRetry(workflow.PhaseBegin, policy),
+			workflow.WithBegin(failTimes(1, errTransient, &first)),
+			workflow.WithBegin(failTimes(2, errTransient, &second)),
+		)
+		Expect(err).Should(BeNil())
+		Expect(first).To(Equal(2))
+		Expect(second).To(Equal(3))
+	})
+
+	It("restarts the whole workflow from begin", func() {
+		begins, calls, rollbacks := 0, 0, 0
+		data, err := workflow.StartWorkFlow(
+			failTimes(1, errTransient, &calls),
+			workflow.WithRunRetry(policy),
+			workflow.WithBegin(func(ctx context.Context, data *workflow.WorkData) error {
+				begins++
+				return nil
+			}),
+			workflow.WithRollback(func(ctx context.Context, data *workflow.WorkData) error {
+				rollbacks++
+				return nil
+			}),
+		)
+		Expect(err).Should(BeNil())
+		Expect(begins).To(Equal(2))
+		Expect(rollbacks).To(Equal(1))
+		Expect(data.Attempt()).To(Equal(2))
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeCommitted))
+	})
+
+	It("restarts on gorm serialization failures", func() {
+		calls := 0
+		data, err := workflow.StartWorkFlow(
+			failTimes(2, sqlStateError("40001"), &calls),
+			workflow.WithGormV2Retry(3),
+		)
+		Expect(err).Should(BeNil())
+		Expect(data.Attempt()).To(Equal(3))
+		Expect(workflow.IsSerializationFailure(errFatal)).To(BeFalse())
+	})
+})
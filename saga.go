@@ -0,0 +1,76 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type compensation struct {
+	name string
+	f    Event
+}
+
+func (d *WorkData) Step(ctx context.Context, name string, do Event, compensate Event) error {
+	start := time.Now()
+	err := safeCall(ctx, do, d)
+	d.recordStep(StepRecord{
+		Name:     name,
+		Started:  start,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+
+	logger := d.Logger.WithField("step", name).WithField("duration", time.Since(start))
+	if err != nil {
+		logger.WithError(err).Warn("step failed")
+		return err
+	}
+	logger.Debug("step completed")
+
+	if compensate != nil {
+		d.pushCompensation(name, compensate)
+	}
+	return nil
+}
+
+func (d *WorkData) pushCompensation(name string, f Event) {
+	d.compensationsLocker.Lock()
+	defer d.compensationsLocker.Unlock()
+	d.compensations = append(d.compensations, compensation{name: name, f: f})
+}
+
+func (d *WorkData) Compensate(ctx context.Context) error {
+	if errs := d.compensate(ctx); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func (d *WorkData) compensate(ctx context.Context) []error {
+	var errs []error
+	for {
+		c, ok := d.popCompensation()
+		if !ok {
+			return errs
+		}
+
+		info := HandlerInfo{Phase: PhaseRollback, Index: -1, Name: c.name}
+		if err := d.observers.handle(ctx, d, info, c.f); err != nil {
+			errs = append(errs, fmt.Errorf("compensate step %q: %w", c.name, err))
+		}
+	}
+}
+
+func (d *WorkData) popCompensation() (compensation, bool) {
+	d.compensationsLocker.Lock()
+	defer d.compensationsLocker.Unlock()
+
+	n := len(d.compensations)
+	if n == 0 {
+		return compensation{}, false
+	}
+	c := d.compensations[n-1]
+	d.compensations = d.compensations[:n-1]
+	return c, true
+}
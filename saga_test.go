@@ -0,0 +1,99 @@
+package workflow_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Saga", func() {
+	var compensated []string
+
+	BeforeEach(func() {
+		compensated = []string{}
+	})
+
+	compensate := func(name string) workflow.Event {
+		return func(ctx context.Context, data *workflow.WorkData) error {
+			compensated = append(compensated, name)
+			return nil
+		}
+	}
+
+	nop := func(ctx context.Context, data *workflow.WorkData) error {
+		return nil
+	}
+
+	It("does not compensate committed steps", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			if err := data.Step(ctx, "a", nop, compensate("a")); err != nil {
+				return err
+			}
+			return data.Step(ctx, "b", nop, compensate("b"))
+		})
+		Expect(err).Should(BeNil())
+		Expect(compensated).To(BeEmpty())
+	})
+
+	It("compensates completed steps in reverse order on error", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			if err := data.Step(ctx, "a", nop, compensate("a")); err != nil {
+				return err
+			}
+			if err := data.Step(ctx, "b", nop, compensate("b")); err != nil {
+				return err
+			}
+			return data.Step(ctx, "c", func(ctx context.Context, data *workflow.WorkData) error {
+				return fmt.Errorf("c")
+			}, compensate("c"))
+		})
+		Expect(err).ShouldNot(BeNil())
+		Expect(compensated).To(Equal([]string{"b", "a"}))
+	})
+
+	It("compensates completed steps on abort", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			if err := data.Step(ctx, "a", nop, compensate("a")); err != nil {
+				return err
+			}
+			data.Abort()
+			return nil
+		})
+		Expect(err).Should(BeNil())
+		Expect(compensated).To(Equal([]string{"a"}))
+	})
+
+	It("compensates before the rollback handlers", func() {
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				if err := data.Step(ctx, "a", nop, compensate("a")); err != nil {
+					return err
+				}
+				return fmt.Errorf("work")
+			},
+			workflow.WithCommit(compensate("commit")),
+			workflow.WithRollback(compensate("rollback")),
+		)
+		Expect(err).ShouldNot(BeNil())
+		Expect(compensated).To(Equal([]string{"a", "rollback"}))
+	})
+
+	It("runs every compensation when one fails", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			if err := data.Step(ctx, "a", nop, compensate("a")); err != nil {
+				return err
+			}
+			if err := data.Step(ctx, "b", nop, func(ctx context.Context, data *workflow.WorkData) error {
+				return fmt.Errorf("b")
+			}); err != nil {
+				return err
+			}
+			return fmt.Errorf("work")
+		})
+		Expect(err).Should(MatchError(ContainSubstring(`compensate step "b"`)))
+		Expect(compensated).To(Equal([]string{"a"}))
+	})
+})
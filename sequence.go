@@ -0,0 +1,74 @@
+package workflow
+
+import (
+	"context"
+	"time"
+)
+
+type Step struct {
+	Name       string
+	Event      Event
+	Compensate Event
+	// Skip, when it returns true, leaves the step out of the sequence.
+	Skip Predicate
+}
+
+type StepRecord struct {
+	Name     string
+	Skipped  bool
+	Started  time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// Sequence runs the steps one after another through WorkData.Step, stopping
+// at the first failure or abort.
+func Sequence(steps ...Step) Event {
+	return func(ctx context.Context, data *WorkData) error {
+		for _, step := range steps {
+			if data.IsAborted() {
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				data.AbortWithReason(err)
+				return nil
+			}
+
+			if step.Skip != nil && step.Skip(ctx, data) {
+				data.recordStep(StepRecord{Name: step.Name, Skipped: true, Started: time.Now()})
+				data.Logger.WithField("step", step.Name).Debug("step skipped")
+				continue
+			}
+
+			if err := data.Step(ctx, step.Name, step.Event, step.Compensate); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (d *WorkData) recordStep(record StepRecord) {
+	d.stepsLocker.Lock()
+	defer d.stepsLocker.Unlock()
+	d.steps = append(d.steps, record)
+}
+
+// Steps returns the records of the steps run so far, in order.
+func (d *WorkData) Steps() []StepRecord {
+	d.stepsLocker.Lock()
+	defer d.stepsLocker.Unlock()
+	return append([]StepRecord(nil), d.steps...)
+}
+
+// LastStep returns the record of the latest step which was not skipped.
+func (d *WorkData) LastStep() (StepRecord, bool) {
+	d.stepsLocker.Lock()
+	defer d.stepsLocker.Unlock()
+	for i := len(d.steps) - 1; i >= 0; i-- {
+		if !d.steps[i].Skipped {
+			return d.steps[i], true
+		}
+	}
+	return StepRecord{}, false
+}
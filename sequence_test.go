@@ -0,0 +1,89 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sequence", func() {
+	errCharge := errors.New("charge")
+
+	var ran, compensated []string
+
+	BeforeEach(func() {
+		ran = []string{}
+		compensated = []string{}
+	})
+
+	step := func(name string) workflow.Step {
+		return workflow.Step{
+			Name: name,
+			Event: func(ctx context.Context, data *workflow.WorkData) error {
+				ran = append(ran, name)
+				return nil
+			},
+			Compensate: func(ctx context.Context, data *workflow.WorkData) error {
+				compensated = append(compensated, name)
+				return nil
+			},
+		}
+	}
+
+	It("runs the steps in order and records them", func() {
+		skipped := step("gift")
+		skipped.Skip = func(ctx context.Context, data *workflow.WorkData) bool {
+			_, ok := data.Get("gift")
+			return !ok
+		}
+
+		data, err := workflow.StartWorkFlow(workflow.Sequence(step("reserve"), skipped, step("charge")))
+		Expect(err).Should(BeNil())
+		Expect(ran).To(Equal([]string{"reserve", "charge"}))
+
+		steps := data.Steps()
+		Expect(steps).To(HaveLen(3))
+		Expect(steps[0].Name).To(Equal("reserve"))
+		Expect(steps[1].Skipped).To(BeTrue())
+		Expect(steps[2].Err).Should(BeNil())
+		Expect(steps[2].Duration).To(BeNumerically(">=", 0))
+	})
+
+	It("stops at the failing step and compensates the completed ones", func() {
+		charge := step("charge")
+		charge.Event = func(ctx context.Context, data *workflow.WorkData) error {
+			return errCharge
+		}
+
+		var last workflow.StepRecord
+		data, err := workflow.StartWorkFlow(
+			workflow.Sequence(step("reserve"), charge, step("ship")),
+			workflow.WithRollback(func(ctx context.Context, data *workflow.WorkData) error {
+				last, _ = data.LastStep()
+				return nil
+			}),
+		)
+		Expect(err).Should(MatchError(errCharge))
+		Expect(ran).To(Equal([]string{"reserve"}))
+		Expect(compensated).To(Equal([]string{"reserve"}))
+		Expect(last.Name).To(Equal("charge"))
+		Expect(last.Err).To(Equal(errCharge))
+		Expect(data.Steps()).To(HaveLen(2))
+	})
+
+	It("stops when a step aborts", func() {
+		abort := step("check")
+		abort.Event = func(ctx context.Context, data *workflow.WorkData) error {
+			data.Abort()
+			return nil
+		}
+
+		data, err := workflow.StartWorkFlow(workflow.Sequence(abort, step("charge")))
+		Expect(err).Should(BeNil())
+		Expect(ran).To(BeEmpty())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeAborted))
+	})
+})
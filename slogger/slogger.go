@@ -0,0 +1,34 @@
+//go:build go1.21
+
+// Package slogger adapts a log/slog logger to workflow.Logger.
+package slogger
+
+import (
+	"log/slog"
+
+	"github.com/chein-huang/workflow"
+)
+
+type logger struct {
+	logger *slog.Logger
+}
+
+// New adapts l to workflow.Logger:
+//
+//	workflow.StartWorkFlow(work, workflow.WithLog(slogger.New(slog.Default())))
+func New(l *slog.Logger) workflow.Logger {
+	return logger{logger: l}
+}
+
+func (l logger) WithField(key string, value interface{}) workflow.Logger {
+	return logger{logger: l.logger.With(key, value)}
+}
+
+func (l logger) WithError(err error) workflow.Logger {
+	return logger{logger: l.logger.With("error", err)}
+}
+
+func (l logger) Debug(msg string) { l.logger.Debug(msg) }
+func (l logger) Info(msg string)  { l.logger.Info(msg) }
+func (l logger) Warn(msg string)  { l.logger.Warn(msg) }
+func (l logger) Error(msg string) { l.logger.Error(msg) }
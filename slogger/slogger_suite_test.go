@@ -0,0 +1,15 @@
+//go:build go1.21
+
+package slogger_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSlogger(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Slogger Suite")
+}
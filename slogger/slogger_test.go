@@ -0,0 +1,35 @@
+//go:build go1.21
+
+package slogger_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/chein-huang/workflow"
+	"github.com/chein-huang/workflow/slogger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logger", func() {
+	It("logs through slog", func() {
+		buf := &bytes.Buffer{}
+		l := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				data.Logger.WithField("order", 42).Info("working")
+				return errors.New("work")
+			},
+			workflow.WithLog(slogger.New(l)),
+		)
+		Expect(err).ShouldNot(BeNil())
+		Expect(buf.String()).To(ContainSubstring(`"msg":"working","from":"workflow"`))
+		Expect(buf.String()).To(ContainSubstring(`"order":42`))
+		Expect(buf.String()).To(ContainSubstring(`"level":"ERROR","msg":"handler failed"`))
+		Expect(buf.String()).To(ContainSubstring(`"error":"work"`))
+	})
+})
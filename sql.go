@@ -0,0 +1,103 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+const (
+	SQLDBKey = "sql-db"
+)
+
+var (
+	sqlTxKey        = NewKey[*sql.Tx](SQLDBKey)
+	sqlSavepointKey = NewKey[string]("sql-savepoint")
+)
+
+type sqlTxStateKey struct{}
+
+// txState is shared by the begin handler, the commit handler and the
+// compensation of a transaction, so the compensation knows the transaction
+// committed wherever it runs.
+type txState struct {
+	committed atomic.Bool
+}
+
+func MustGetSQLTx(data *WorkData) *sql.Tx {
+	return sqlTxKey.MustGet(data)
+}
+
+func GetSQLTx(data *WorkData) (*sql.Tx, error) {
+	return sqlTxKey.Get(data)
+}
+
+// WithSQLDB runs the workflow in a database/sql transaction begun with opts.
+// In a sub workflow of a workflow which already holds one, a savepoint is
+// used instead.
+func WithSQLDB(db *sql.DB, opts *sql.TxOptions) Options {
+	return applyFunc(func(data *WorkData) {
+		data.workBegin.Add(func(ctx context.Context, data *WorkData) error {
+			if tx, ok := parentSQLTx(data); ok {
+				name := fmt.Sprintf("workflow_sp_%d", atomic.AddUint64(&savepointSeq, 1))
+				if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+					return err
+				}
+				data.setLocal(sqlSavepointKey, name)
+				data.pushCompensation(SQLDBKey, func(ctx context.Context, data *WorkData) error {
+					_, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+					return err
+				})
+				return nil
+			}
+
+			// the transaction outlives the begin phase, it must not be
+			// rolled back by database/sql when the phase context ends.
+			tx, err := db.BeginTx(detach(ctx), opts)
+			if err != nil {
+				return err
+			}
+			data.setOwned(sqlTxKey, tx)
+			state := &txState{}
+			data.setLocal(sqlTxStateKey{}, state)
+			data.pushCompensation(SQLDBKey, func(ctx context.Context, data *WorkData) error {
+				if state.committed.Load() {
+					return fmt.Errorf("sql transaction: %w", ErrAlreadyCommitted)
+				}
+				// a transaction which failed to commit is already rolled back.
+				if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+					return err
+				}
+				return nil
+			})
+			return nil
+		})
+		data.workCommit.Add(func(ctx context.Context, data *WorkData) error {
+			if _, ok := data.getLocal(sqlSavepointKey); ok {
+				return nil
+			}
+			// a recovered workflow lost its transaction with the process.
+			tx, err := GetSQLTx(data)
+			if err != nil {
+				return err
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			if state, ok := data.getLocal(sqlTxStateKey{}); ok {
+				state.(*txState).committed.Store(true)
+			}
+			return nil
+		})
+	})
+}
+
+func parentSQLTx(data *WorkData) (*sql.Tx, bool) {
+	if data.Parent() == nil {
+		return nil, false
+	}
+	tx, err := sqlTxKey.Get(data.Parent())
+	return tx, err == nil
+}
@@ -0,0 +1,208 @@
+package workflow_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chein-huang/workflow"
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeDriver records the transactions begun by its connections.
+type fakeDriver struct {
+	mux       sync.Mutex
+	txs       []driver.TxOptions
+	done      []string
+	commitErr error
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{driver: d}, nil
+}
+
+func (d *fakeDriver) record(event string) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.done = append(d.done, event)
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.driver.mux.Lock()
+	defer c.driver.mux.Unlock()
+	c.driver.txs = append(c.driver.txs, opts)
+	return fakeTx{driver: c.driver}, nil
+}
+
+type fakeTx struct {
+	driver *fakeDriver
+}
+
+func (tx fakeTx) Commit() error {
+	tx.driver.record("commit")
+	return tx.driver.commitErr
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.driver.record("rollback")
+	return nil
+}
+
+var fakeDriverSeq int
+
+func openFakeDB() (*sql.DB, *fakeDriver) {
+	fakeDriverSeq++
+	d := &fakeDriver{}
+	name := fmt.Sprintf("fake%d", fakeDriverSeq)
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	Expect(err).Should(BeNil())
+	return db, d
+}
+
+var _ = Describe("WithSQLDB", func() {
+	var db *sql.DB
+
+	BeforeEach(func() {
+		testDBSeq++
+		var err error
+		db, err = sql.Open("sqlite3", fmt.Sprintf("file:sql%d?mode=memory&cache=shared", testDBSeq))
+		Expect(err).Should(BeNil())
+		db.SetMaxOpenConns(1)
+		_, err = db.Exec("CREATE TABLE items (name TEXT)")
+		Expect(err).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	insert := func(name string) workflow.Event {
+		return func(ctx context.Context, data *workflow.WorkData) error {
+			_, err := workflow.MustGetSQLTx(data).ExecContext(ctx, "INSERT INTO items (name) VALUES (?)", name)
+			return err
+		}
+	}
+
+	names := func() []string {
+		rows, err := db.Query("SELECT name FROM items ORDER BY rowid")
+		Expect(err).Should(BeNil())
+		defer rows.Close()
+		var names []string
+		for rows.Next() {
+			var name string
+			Expect(rows.Scan(&name)).Should(BeNil())
+			names = append(names, name)
+		}
+		return names
+	}
+
+	It("commits the transaction", func() {
+		_, err := workflow.StartWorkFlow(insert("a"), workflow.WithSQLDB(db, nil))
+		Expect(err).Should(BeNil())
+		Expect(names()).To(Equal([]string{"a"}))
+	})
+
+	It("rolls back the transaction", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			if err := insert("a")(ctx, data); err != nil {
+				return err
+			}
+			return errors.New("work")
+		}, workflow.WithSQLDB(db, nil))
+		Expect(err).ShouldNot(BeNil())
+		Expect(names()).To(BeEmpty())
+	})
+
+	It("reports the committed transaction when a later commit handler fails", func() {
+		data, err := workflow.StartWorkFlow(insert("a"),
+			workflow.WithSQLDB(db, nil),
+			workflow.WithCommit(func(ctx context.Context, data *workflow.WorkData) error {
+				return errors.New("commit")
+			}),
+		)
+		Expect(err).ShouldNot(BeNil())
+		Expect(errors.Is(err, workflow.ErrAlreadyCommitted)).To(BeTrue())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRollbackFailed))
+		Expect(names()).To(Equal([]string{"a"}))
+	})
+
+	It("rolls back the transaction when an earlier commit handler fails", func() {
+		data, err := workflow.StartWorkFlow(insert("a"),
+			workflow.WithCommit(func(ctx context.Context, data *workflow.WorkData) error {
+				return errors.New("commit")
+			}),
+			workflow.WithSQLDB(db, nil),
+		)
+		Expect(err).ShouldNot(BeNil())
+		Expect(errors.Is(err, workflow.ErrAlreadyCommitted)).To(BeFalse())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRolledBack))
+		Expect(names()).To(BeEmpty())
+	})
+
+	It("uses a savepoint in sub workflows", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			if err := insert("parent")(ctx, data); err != nil {
+				return err
+			}
+			_, err := data.Sub(ctx, func(ctx context.Context, child *workflow.WorkData) error {
+				if err := insert("child")(ctx, child); err != nil {
+					return err
+				}
+				return errors.New("child")
+			}, workflow.WithSQLDB(db, nil))
+			Expect(err).ShouldNot(BeNil())
+			return nil
+		}, workflow.WithSQLDB(db, nil))
+		Expect(err).Should(BeNil())
+		Expect(names()).To(Equal([]string{"parent"}))
+	})
+
+	It("begins the transaction with the options", func() {
+		fake, d := openFakeDB()
+		defer fake.Close()
+
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			return nil
+		}, workflow.WithSQLDB(fake, &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}))
+		Expect(err).Should(BeNil())
+		Expect(d.txs).To(Equal([]driver.TxOptions{{Isolation: driver.IsolationLevel(sql.LevelSerializable), ReadOnly: true}}))
+		Expect(d.done).To(Equal([]string{"commit"}))
+	})
+
+	It("keeps the transaction open after the begin phase deadline", func() {
+		fake, d := openFakeDB()
+		defer fake.Close()
+
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			return nil
+		},
+			workflow.WithSQLDB(fake, nil),
+			workflow.WithPhaseTimeout(workflow.PhaseBegin, time.Second),
+		)
+		Expect(err).Should(BeNil())
+		Expect(d.done).To(Equal([]string{"commit"}))
+	})
+})
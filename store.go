@@ -0,0 +1,269 @@
+package workflow
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// State is the persisted progress of a workflow run.
+type State struct {
+	ID        string
+	Name      string
+	Phase     Phase
+	Progress  int
+	Values    map[string]json.RawMessage
+	InDoubt   []string
+	Done      bool
+	Outcome   Outcome
+	UpdatedAt time.Time
+}
+
+type Store interface {
+	Save(ctx context.Context, state *State) error
+	Incomplete(ctx context.Context) ([]*State, error)
+}
+
+// DurableKey is a typed key whose value is persisted with the workflow state.
+type DurableKey interface {
+	String() string
+	marshal(data *WorkData) (json.RawMessage, bool, error)
+	unmarshal(data *WorkData, raw json.RawMessage) error
+}
+
+func (k *Key[T]) marshal(data *WorkData) (json.RawMessage, bool, error) {
+	value, err := k.Get(data)
+	if err != nil {
+		return nil, false, nil
+	}
+	raw, err := json.Marshal(value)
+	return raw, true, err
+}
+
+func (k *Key[T]) unmarshal(data *WorkData, raw json.RawMessage) error {
+	var value T
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return err
+	}
+	k.Set(data, value)
+	return nil
+}
+
+// WithStore persists the workflow state after every handler. Only the values
+// of the durable keys are saved.
+func WithStore(store Store, keys ...DurableKey) Options {
+	return applyFunc(func(data *WorkData) {
+		data.store = store
+		data.durableKeys = append(data.durableKeys, keys...)
+	})
+}
+
+func (d *WorkData) ID() string {
+	return d.id
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (d *WorkData) persist(ctx context.Context, phase Phase, progress int) error {
+	if d.store == nil || d.parent != nil {
+		return nil
+	}
+
+	values := make(map[string]json.RawMessage, len(d.durableKeys))
+	for _, key := range d.durableKeys {
+		raw, ok, err := key.marshal(d)
+		if err != nil {
+			return fmt.Errorf("persist %v: %w", key, err)
+		}
+		if ok {
+			values[key.String()] = raw
+		}
+	}
+
+	inDoubt := d.InDoubt()
+	return d.store.Save(ctx, &State{
+		ID:        d.id,
+		Name:      d.name,
+		Phase:     phase,
+		Progress:  progress,
+		Values:    values,
+		InDoubt:   inDoubt,
+		Done:      d.Outcome() != OutcomeRunning && len(inDoubt) == 0,
+		Outcome:   d.Outcome(),
+		UpdatedAt: time.Now(),
+	})
+}
+
+func (d *WorkData) restore(state *State) error {
+	d.id = state.ID
+	for _, name := range state.InDoubt {
+		d.setResourceState(name, resourcePrepared)
+	}
+	for _, key := range d.durableKeys {
+		if raw, ok := state.Values[key.String()]; ok {
+			if err := key.unmarshal(d, raw); err != nil {
+				return fmt.Errorf("restore %v: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Recover finds the incomplete workflows of the store and completes them with
+// their definitions: a workflow which crashed while committing or finishing
+// resumes after the last completed handler, any other one is rolled back.
+// Resources left in doubt are committed or rolled back likewise, a workflow
+// which ended with resources in doubt is only completed by resolving them.
+// Compensations of steps are held in memory and are lost with the process,
+// so only the rollback handlers run.
+//
+// Recover takes every incomplete workflow for crashed, it must only run when
+// no run of the store can still be in progress, e.g. on the startup of a
+// single instance. Instances sharing a store use RecoverOlderThan.
+func Recover(ctx context.Context, store Store, defs ...*Workflow) ([]*WorkData, error) {
+	return RecoverOlderThan(ctx, store, 0, defs...)
+}
+
+// RecoverOlderThan is Recover for the workflows whose state was not saved for
+// at least age, the others are left to the runs which may still own them. The
+// state is saved after every handler, so age must be longer than the slowest
+// handler of defs.
+func RecoverOlderThan(ctx context.Context, store Store, age time.Duration, defs ...*Workflow) ([]*WorkData, error) {
+	states, err := store.Incomplete(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*Workflow, len(defs))
+	for _, w := range defs {
+		byName[w.name] = w
+	}
+
+	var recovered []*WorkData
+	var errs []error
+	for _, state := range states {
+		if age > 0 && time.Since(state.UpdatedAt) < age {
+			continue
+		}
+
+		w, ok := byName[state.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("recover workflow %s: definition %q is not registered", state.ID, state.Name))
+			continue
+		}
+
+		data := w.template.clone()
+		if err := data.restore(state); err != nil {
+			errs = append(errs, fmt.Errorf("recover workflow %s: %w", state.ID, err))
+			continue
+		}
+		data.recover(ctx, state)
+		recovered = append(recovered, data)
+	}
+
+	if len(errs) > 0 {
+		return recovered, errors.New(joinErrors(errs))
+	}
+	return recovered, nil
+}
+
+func (d *WorkData) recover(ctx context.Context, state *State) {
+	ctx = d.annotate(ctx)
+	defer d.finalize(ctx)
+
+	d.Logger.WithField("phase", state.Phase).WithField("progress", state.Progress).Info("recovering workflow")
+
+	var err error
+	switch {
+	case state.Outcome != OutcomeRunning:
+		d.resolveInDoubt(ctx, state.Outcome == OutcomeCommitted)
+		d.phase = state.Phase
+		d.setResult(state.Outcome, d.withInDoubt(nil))
+	case state.Phase == PhaseCommit:
+		d.workCommit.resume(state.Progress)
+		err = runPhase(ctx, d, PhaseCommit, func(ctx context.Context) error {
+			d.setState(d.workCommit)
+			return d.Next(ctx)
+		})
+		if err != nil || d.IsAborted() {
+			abandon(ctx, d, PhaseCommit, err, OutcomeRolledBack, d.rollback)
+			break
+		}
+		fallthrough
+	case state.Phase == PhaseFinish:
+		progress := 0
+		if state.Phase == PhaseFinish {
+			progress = state.Progress
+		}
+		d.workFinish.resume(progress)
+		d.setResult(OutcomeCommitted, d.withInDoubt(finish(ctx, d)))
+	default:
+		d.resolveInDoubt(ctx, false)
+		if state.Phase == PhaseRollback {
+			d.workRollback.resume(state.Progress)
+		}
+		abandon(ctx, d, state.Phase, nil, OutcomeRolledBack, d.rollback)
+		if d.Outcome() == OutcomeAborted {
+			d.setResult(OutcomeRolledBack, nil)
+		}
+	}
+
+	if err := d.persist(detach(ctx), d.phase, d.Progress(d.phase)); err != nil {
+		d.Logger.WithError(err).Error("persist recovered workflow")
+	}
+}
+
+type MemoryStore struct {
+	mux    sync.Mutex
+	states map[string]*State
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]*State)}
+}
+
+func (s *MemoryStore) Save(ctx context.Context, state *State) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	copied := *state
+	s.states[state.ID] = &copied
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (*State, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	state, ok := s.states[id]
+	if !ok {
+		return nil, false
+	}
+	copied := *state
+	return &copied, true
+}
+
+func (s *MemoryStore) Incomplete(ctx context.Context) ([]*State, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	var states []*State
+	for _, state := range s.states {
+		if !state.Done {
+			copied := *state
+			states = append(states, &copied)
+		}
+	}
+	return states, nil
+}
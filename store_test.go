@@ -0,0 +1,196 @@
+package workflow_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	orderID := workflow.NewKey[int]("order-id")
+
+	var ran []string
+
+	BeforeEach(func() {
+		ran = []string{}
+	})
+
+	record := func(name string) workflow.Event {
+		return func(ctx context.Context, data *workflow.WorkData) error {
+			ran = append(ran, name)
+			return nil
+		}
+	}
+	define := func(store workflow.Store) *workflow.Workflow {
+		return workflow.MustDefine(
+			"order",
+			workflow.WithStore(store, orderID),
+			workflow.WithCommit(record("commit-0")),
+			workflow.WithCommit(record("commit-1")),
+			workflow.WithCommit(record("commit-2")),
+			workflow.WithRollback(record("rollback")),
+			workflow.WithFinish(record("finish")),
+		)
+	}
+
+	It("persists the progress after every handler", func() {
+		store := workflow.NewMemoryStore()
+		var progress *workflow.State
+		w := workflow.MustDefine(
+			"order",
+			workflow.WithStore(store, orderID),
+			workflow.WithCommit(record("commit-0")),
+			workflow.WithCommit(func(ctx context.Context, data *workflow.WorkData) error {
+				progress, _ = store.Get(ctx, data.ID())
+				return nil
+			}),
+		)
+
+		data, err := w.Run(context.Background(), func(ctx context.Context, data *workflow.WorkData) error {
+			orderID.Set(data, 42)
+			return nil
+		})
+		Expect(err).Should(BeNil())
+		Expect(progress.Name).To(Equal("order"))
+		Expect(progress.Phase).To(Equal(workflow.PhaseCommit))
+		Expect(progress.Progress).To(Equal(1))
+		Expect(progress.Done).To(BeFalse())
+		Expect(progress.Values).To(HaveKeyWithValue("order-id", json.RawMessage("42")))
+
+		state, ok := store.Get(context.Background(), data.ID())
+		Expect(ok).To(BeTrue())
+		Expect(state.Done).To(BeTrue())
+		Expect(state.Outcome).To(Equal(workflow.OutcomeCommitted))
+	})
+
+	It("resumes a workflow which crashed while committing", func() {
+		store := workflow.NewMemoryStore()
+		Expect(store.Save(context.Background(), &workflow.State{
+			ID:       "crashed",
+			Name:     "order",
+			Phase:    workflow.PhaseCommit,
+			Progress: 1,
+			Values:   map[string]json.RawMessage{"order-id": json.RawMessage("42")},
+		})).Should(BeNil())
+
+		recovered, err := workflow.Recover(context.Background(), store, define(store))
+		Expect(err).Should(BeNil())
+		Expect(recovered).To(HaveLen(1))
+		Expect(recovered[0].ID()).To(Equal("crashed"))
+		Expect(orderID.MustGet(recovered[0])).To(Equal(42))
+		Expect(recovered[0].Outcome()).To(Equal(workflow.OutcomeCommitted))
+		Expect(ran).To(Equal([]string{"commit-1", "commit-2", "finish"}))
+
+		incomplete, err := store.Incomplete(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(incomplete).To(BeEmpty())
+	})
+
+	It("rolls back a workflow which crashed before committing", func() {
+		store := workflow.NewMemoryStore()
+		Expect(store.Save(context.Background(), &workflow.State{
+			ID:    "crashed",
+			Name:  "order",
+			Phase: workflow.PhaseWork,
+		})).Should(BeNil())
+
+		recovered, err := workflow.Recover(context.Background(), store, define(store))
+		Expect(err).Should(BeNil())
+		Expect(recovered[0].Outcome()).To(Equal(workflow.OutcomeRolledBack))
+		Expect(ran).To(Equal([]string{"rollback"}))
+
+		state, _ := store.Get(context.Background(), "crashed")
+		Expect(state.Done).To(BeTrue())
+	})
+
+	It("leaves the workflows saved recently to their runs", func() {
+		store := workflow.NewMemoryStore()
+		Expect(store.Save(context.Background(), &workflow.State{
+			ID:        "running",
+			Name:      "order",
+			Phase:     workflow.PhaseWork,
+			UpdatedAt: time.Now(),
+		})).Should(BeNil())
+		Expect(store.Save(context.Background(), &workflow.State{
+			ID:        "crashed",
+			Name:      "order",
+			Phase:     workflow.PhaseWork,
+			UpdatedAt: time.Now().Add(-time.Hour),
+		})).Should(BeNil())
+
+		recovered, err := workflow.RecoverOlderThan(context.Background(), store, time.Minute, define(store))
+		Expect(err).Should(BeNil())
+		Expect(recovered).To(HaveLen(1))
+		Expect(recovered[0].ID()).To(Equal("crashed"))
+
+		state, _ := store.Get(context.Background(), "running")
+		Expect(state.Done).To(BeFalse())
+	})
+
+	It("rolls back a gorm workflow which crashed before its transaction committed", func() {
+		store := workflow.NewMemoryStore()
+		w := workflow.MustDefine("order",
+			workflow.WithStore(store),
+			workflow.WithGormV2(openTestDB()),
+			workflow.WithRollback(record("rollback")),
+		)
+		Expect(store.Save(context.Background(), &workflow.State{
+			ID:    "crashed",
+			Name:  "order",
+			Phase: workflow.PhaseCommit,
+		})).Should(BeNil())
+
+		recovered, err := workflow.Recover(context.Background(), store, w)
+		Expect(err).Should(BeNil())
+		Expect(recovered[0].Outcome()).To(Equal(workflow.OutcomeRolledBack))
+		Expect(errors.Is(recovered[0].Result().Err, workflow.ErrKeyNotFound)).To(BeTrue())
+		Expect(ran).To(Equal([]string{"rollback"}))
+	})
+
+	It("reports workflows without a definition", func() {
+		store := workflow.NewMemoryStore()
+		Expect(store.Save(context.Background(), &workflow.State{ID: "crashed", Name: "unknown"})).Should(BeNil())
+
+		_, err := workflow.Recover(context.Background(), store, define(store))
+		Expect(err).Should(MatchError(ContainSubstring(`definition "unknown" is not registered`)))
+	})
+
+	It("keeps the states in a database", func() {
+		store := workflow.NewGormStore(openTestDB())
+		Expect(store.AutoMigrate()).Should(BeNil())
+
+		_, err := define(store).Run(context.Background(), func(ctx context.Context, data *workflow.WorkData) error {
+			orderID.Set(data, 42)
+			return nil
+		})
+		Expect(err).Should(BeNil())
+
+		Expect(store.Save(context.Background(), &workflow.State{
+			ID:       "crashed",
+			Name:     "order",
+			Phase:    workflow.PhaseCommit,
+			Progress: 2,
+			Values:   map[string]json.RawMessage{"order-id": json.RawMessage("7")},
+		})).Should(BeNil())
+
+		incomplete, err := store.Incomplete(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(incomplete).To(HaveLen(1))
+		Expect(incomplete[0].Phase).To(Equal(workflow.PhaseCommit))
+
+		ran = []string{}
+		recovered, err := workflow.Recover(context.Background(), store, define(store))
+		Expect(err).Should(BeNil())
+		Expect(orderID.MustGet(recovered[0])).To(Equal(7))
+		Expect(ran).To(Equal([]string{"commit-2", "finish"}))
+
+		incomplete, err = store.Incomplete(context.Background())
+		Expect(err).Should(BeNil())
+		Expect(incomplete).To(BeEmpty())
+	})
+})
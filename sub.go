@@ -0,0 +1,56 @@
+package workflow
+
+import (
+	"context"
+)
+
+// Sub runs a child workflow sharing the context values, the logger and the
+// observers of d. A child which fails is rolled back on its own. A committed
+// child is only committed into d: its compensations are handed over to d, so
+// rolling back d also undoes the child, and its finish handlers run after the
+// ones of d once d commits. A transaction the child begins itself, because d
+// holds none, is kept out of the shared values; it commits with the child and
+// rolling back d reports it with ErrAlreadyCommitted.
+func (d *WorkData) Sub(ctx context.Context, f Event, opts ...Options) (*WorkData, error) {
+	child, err := d.subWorkflow(opts).run(ctx, f, d)
+	if child.Outcome() == OutcomeCommitted {
+		child.compensationsLocker.Lock()
+		compensations := child.compensations
+		child.compensations = nil
+		child.compensationsLocker.Unlock()
+
+		d.compensationsLocker.Lock()
+		d.compensations = append(d.compensations, compensations...)
+		d.subs = append(d.subs, child)
+		d.compensationsLocker.Unlock()
+	}
+	return child, err
+}
+
+func (d *WorkData) subWorkflow(opts []Options) *Workflow {
+	template := NewWorkData()
+	template.name = d.name
+	template.Logger = d.Logger
+	template.logLevels = d.logLevels
+	template.observers = append(observers(nil), d.observers...)
+	for _, opt := range opts {
+		opt.Apply(template)
+	}
+	return &Workflow{name: d.name, template: template}
+}
+
+// takeSubs returns the committed sub workflows waiting to be finished.
+func (d *WorkData) takeSubs() []*WorkData {
+	d.compensationsLocker.Lock()
+	defer d.compensationsLocker.Unlock()
+	subs := d.subs
+	d.subs = nil
+	return subs
+}
+
+func (d *WorkData) attach(parent *WorkData) {
+	d.parent = parent
+	d.parentID = parent.id
+	d.ctx = parent.ctx
+	d.ctxLocker = parent.ctxLocker
+}
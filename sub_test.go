@@ -0,0 +1,189 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chein-huang/workflow"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	gormV2 "gorm.io/gorm"
+)
+
+type subRecord struct {
+	ID   uint
+	Name string
+}
+
+var _ = Describe("Sub", func() {
+	var db *gormV2.DB
+
+	BeforeEach(func() {
+		db = openTestDB()
+		Expect(db.AutoMigrate(&subRecord{})).Should(BeNil())
+	})
+
+	insert := func(name string) workflow.Event {
+		return func(ctx context.Context, data *workflow.WorkData) error {
+			return workflow.MustGetGormTx(data).Create(&subRecord{Name: name}).Error
+		}
+	}
+	names := func() []string {
+		var records []subRecord
+		Expect(db.Order("id").Find(&records).Error).Should(BeNil())
+		result := []string{}
+		for _, r := range records {
+			result = append(result, r.Name)
+		}
+		return result
+	}
+
+	It("shares the context values with the parent", func() {
+		data, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			data.Set("parent", 1)
+			child, err := data.Sub(ctx, func(ctx context.Context, child *workflow.WorkData) error {
+				Expect(child.MustGet("parent")).To(Equal(1))
+				child.Set("child", 2)
+				return nil
+			})
+			Expect(child.Parent()).To(Equal(data))
+			return err
+		})
+		Expect(err).Should(BeNil())
+		Expect(data.MustGet("child")).To(Equal(2))
+	})
+
+	It("rolls back a failed child to its savepoint", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			if err := insert("parent")(ctx, data); err != nil {
+				return err
+			}
+			child, err := data.Sub(ctx, func(ctx context.Context, child *workflow.WorkData) error {
+				if err := insert("child")(ctx, child); err != nil {
+					return err
+				}
+				return errors.New("child")
+			}, workflow.WithGormV2(db))
+			Expect(err).ShouldNot(BeNil())
+			Expect(child.Outcome()).To(Equal(workflow.OutcomeRolledBack))
+			return insert("after")(ctx, data)
+		}, workflow.WithGormV2(db))
+		Expect(err).Should(BeNil())
+		Expect(names()).To(Equal([]string{"parent", "after"}))
+	})
+
+	It("undoes committed children when the parent rolls back", func() {
+		compensated := false
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			_, err := data.Sub(ctx, func(ctx context.Context, child *workflow.WorkData) error {
+				if err := insert("child")(ctx, child); err != nil {
+					return err
+				}
+				return child.Step(ctx, "notify", func(ctx context.Context, data *workflow.WorkData) error {
+					return nil
+				}, func(ctx context.Context, data *workflow.WorkData) error {
+					compensated = true
+					return nil
+				})
+			}, workflow.WithGormV2(db))
+			Expect(err).Should(BeNil())
+			return errors.New("parent")
+		}, workflow.WithGormV2(db))
+		Expect(err).ShouldNot(BeNil())
+		Expect(compensated).To(BeTrue())
+		Expect(names()).To(BeEmpty())
+	})
+
+	It("commits children with the parent", func() {
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			_, err := data.Sub(ctx, insert("child"), workflow.WithGormV2(db))
+			if err != nil {
+				return err
+			}
+			return insert("parent")(ctx, data)
+		}, workflow.WithGormV2(db))
+		Expect(err).Should(BeNil())
+		Expect(names()).To(Equal([]string{"child", "parent"}))
+	})
+
+	It("finishes committed children only when the parent commits", func() {
+		var finished []string
+		finish := func(name string) workflow.Options {
+			return workflow.WithFinish(func(ctx context.Context, data *workflow.WorkData) error {
+				finished = append(finished, name)
+				return nil
+			})
+		}
+		parent := func(fail bool) workflow.Event {
+			return func(ctx context.Context, data *workflow.WorkData) error {
+				child, err := data.Sub(ctx, insert("child"), workflow.WithGormV2(db), finish("child"))
+				if err != nil {
+					return err
+				}
+				Expect(child.Outcome()).To(Equal(workflow.OutcomeCommitted))
+				Expect(finished).To(BeEmpty())
+				if fail {
+					return errors.New("parent")
+				}
+				return nil
+			}
+		}
+
+		_, err := workflow.StartWorkFlow(parent(true), workflow.WithGormV2(db), finish("parent"))
+		Expect(err).ShouldNot(BeNil())
+		Expect(finished).To(BeEmpty())
+
+		_, err = workflow.StartWorkFlow(parent(false), workflow.WithGormV2(db), finish("parent"))
+		Expect(err).Should(BeNil())
+		Expect(finished).To(Equal([]string{"parent", "child"}))
+	})
+
+	It("inherits the logger and the observers of the parent", func() {
+		events := []string{}
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			_, err := data.Sub(ctx, func(ctx context.Context, child *workflow.WorkData) error {
+				Expect(child.Logger).To(Equal(workflow.NopLogger{}))
+				return nil
+			})
+			return err
+		}, workflow.WithLog(workflow.NopLogger{}), workflow.WithObserver(&recordingObserver{name: "o", events: &events}))
+		Expect(err).Should(BeNil())
+		starts := 0
+		for _, e := range events {
+			if e == "o:start" {
+				starts++
+			}
+		}
+		Expect(starts).To(Equal(2))
+	})
+
+	It("reports a child transaction which committed when the parent rolls back", func() {
+		data, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			_, err := data.Sub(ctx, insert("child"), workflow.WithGormV2(db))
+			if err != nil {
+				return err
+			}
+			_, err = workflow.GetGormTx(data)
+			Expect(errors.Is(err, workflow.ErrKeyNotFound)).To(BeTrue())
+			_, ok := data.Get(workflow.GormDBKey)
+			Expect(ok).To(BeFalse())
+			return errors.New("parent")
+		})
+		Expect(errors.Is(err, workflow.ErrAlreadyCommitted)).To(BeTrue())
+		Expect(data.Outcome()).To(Equal(workflow.OutcomeRollbackFailed))
+		Expect(names()).To(Equal([]string{"child"}))
+	})
+
+	It("names the savepoint compensations after the transaction", func() {
+		var handlers []workflow.HandlerInfo
+		_, err := workflow.StartWorkFlow(func(ctx context.Context, data *workflow.WorkData) error {
+			_, err := data.Sub(ctx, func(ctx context.Context, child *workflow.WorkData) error {
+				return errors.New("child")
+			}, workflow.WithGormV2(db))
+			Expect(err).ShouldNot(BeNil())
+			return nil
+		}, workflow.WithGormV2(db), workflow.WithObserver(&handlerObserver{handlers: &handlers}))
+		Expect(err).Should(BeNil())
+		Expect(handlers).To(ContainElement(workflow.HandlerInfo{Phase: workflow.PhaseRollback, Index: -1, Name: workflow.GormDBKey}))
+	})
+})
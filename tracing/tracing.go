@@ -0,0 +1,146 @@
+// Package tracing records OpenTelemetry spans of workflow runs through a
+// workflow.Observer.
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/chein-huang/workflow"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/chein-huang/workflow/tracing"
+
+// Tracer is a workflow.Observer starting a root span per run and child spans
+// per phase and handler. The spans are carried by the context passed to the
+// Events, so spans started by the handlers are children of them:
+//
+//	workflow.StartWorkFlow(work, tracing.WithTracing(provider))
+type Tracer struct {
+	workflow.NopObserver
+
+	tracer trace.Tracer
+}
+
+// NewTracer uses the global tracer provider when provider is nil.
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: provider.Tracer(instrumentationName)}
+}
+
+func WithTracing(provider trace.TracerProvider) workflow.Options {
+	return workflow.WithObserver(NewTracer(provider))
+}
+
+type rootSpanKey struct{}
+
+func (t *Tracer) OnStart(ctx context.Context, data *workflow.WorkData) context.Context {
+	attrs := []attribute.KeyValue{
+		attribute.String("workflow.id", data.ID()),
+		attribute.String("workflow.name", data.Name()),
+		attribute.Int("workflow.attempt", data.Attempt()),
+	}
+	if data.ParentID() != "" {
+		attrs = append(attrs, attribute.String("workflow.parent_id", data.ParentID()))
+	}
+	for k, v := range data.Labels() {
+		attrs = append(attrs, attribute.String("workflow.label."+k, v))
+	}
+
+	ctx, span := t.tracer.Start(ctx, spanName(data, ""), trace.WithAttributes(attrs...))
+	return context.WithValue(ctx, rootSpanKey{}, span)
+}
+
+func (t *Tracer) OnEnd(ctx context.Context, data *workflow.WorkData, result workflow.Result) {
+	span := rootSpan(ctx)
+	span.SetAttributes(attribute.String("workflow.outcome", result.Outcome.String()))
+	switch {
+	case result.Err != nil:
+		span.RecordError(result.Err)
+		span.SetStatus(codes.Error, result.Err.Error())
+	case result.Outcome == workflow.OutcomeCommitted:
+		span.SetStatus(codes.Ok, "")
+	default:
+		span.SetStatus(codes.Error, result.Outcome.String())
+	}
+	span.End()
+}
+
+func (t *Tracer) OnPhaseStart(ctx context.Context, data *workflow.WorkData, phase workflow.Phase) context.Context {
+	ctx, _ = t.tracer.Start(ctx, spanName(data, phase.String()),
+		trace.WithAttributes(attribute.String("workflow.phase", phase.String())))
+	return ctx
+}
+
+func (t *Tracer) OnPhaseEnd(ctx context.Context, data *workflow.WorkData, phase workflow.Phase, err error, duration time.Duration) {
+	end(trace.SpanFromContext(ctx), err)
+}
+
+func (t *Tracer) OnHandlerStart(ctx context.Context, data *workflow.WorkData, handler workflow.HandlerInfo) context.Context {
+	ctx, _ = t.tracer.Start(ctx, spanName(data, handler.Phase.String()+" "+handler.Name),
+		trace.WithAttributes(
+			attribute.String("workflow.phase", handler.Phase.String()),
+			attribute.Int("workflow.handler.index", handler.Index),
+			attribute.String("workflow.handler.name", handler.Name),
+		))
+	return ctx
+}
+
+func (t *Tracer) OnHandlerEnd(ctx context.Context, data *workflow.WorkData, handler workflow.HandlerInfo, err error, duration time.Duration) {
+	end(trace.SpanFromContext(ctx), err)
+}
+
+func (t *Tracer) OnAbort(ctx context.Context, data *workflow.WorkData, reason error) {
+	var attrs []attribute.KeyValue
+	if reason != nil {
+		attrs = append(attrs, attribute.String("workflow.abort_reason", reason.Error()))
+	}
+	rootSpan(ctx).AddEvent("abort", trace.WithAttributes(attrs...))
+}
+
+func (t *Tracer) OnPanic(ctx context.Context, data *workflow.WorkData, err *workflow.PanicError) {
+	trace.SpanFromContext(ctx).AddEvent("panic", trace.WithAttributes(
+		attribute.String("exception.message", err.Error()),
+		attribute.String("exception.stacktrace", string(err.Stack)),
+	))
+}
+
+func (t *Tracer) OnRollback(ctx context.Context, data *workflow.WorkData, errs []error) {
+	span := rootSpan(ctx)
+	span.AddEvent("rollback", trace.WithAttributes(attribute.Int("workflow.rollback_errors", len(errs))))
+	for _, err := range errs {
+		span.RecordError(err)
+	}
+}
+
+func rootSpan(ctx context.Context) trace.Span {
+	if span, ok := ctx.Value(rootSpanKey{}).(trace.Span); ok {
+		return span
+	}
+	return trace.SpanFromContext(ctx)
+}
+
+func spanName(data *workflow.WorkData, suffix string) string {
+	name := "workflow"
+	if data.Name() != "" {
+		name += " " + data.Name()
+	}
+	if suffix != "" {
+		name += " " + suffix
+	}
+	return name
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
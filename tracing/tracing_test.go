@@ -0,0 +1,133 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chein-huang/workflow"
+	"github.com/chein-huang/workflow/tracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ = Describe("Tracer", func() {
+	var (
+		exporter *tracetest.InMemoryExporter
+		order    *workflow.Workflow
+	)
+
+	BeforeEach(func() {
+		exporter = tracetest.NewInMemoryExporter()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		order = workflow.MustDefine(
+			"order",
+			tracing.WithTracing(provider),
+			workflow.WithBegin(func(ctx context.Context, data *workflow.WorkData) error {
+				return nil
+			}),
+			workflow.WithRollback(func(ctx context.Context, data *workflow.WorkData) error {
+				return nil
+			}),
+		)
+	})
+
+	spans := func() map[string]tracetest.SpanStub {
+		byName := map[string]tracetest.SpanStub{}
+		for _, span := range exporter.GetSpans() {
+			byName[span.Name] = span
+		}
+		return byName
+	}
+
+	It("creates a root span with child spans per phase and handler", func() {
+		var handlerSpan trace.SpanContext
+		data, err := order.Run(context.Background(), func(ctx context.Context, data *workflow.WorkData) error {
+			handlerSpan = trace.SpanContextFromContext(ctx)
+			return nil
+		})
+		Expect(err).Should(BeNil())
+
+		byName := spans()
+		root, ok := byName["workflow order"]
+		Expect(ok).Should(BeTrue())
+		Expect(root.Parent.IsValid()).Should(BeFalse())
+		Expect(root.Status.Code).Should(Equal(codes.Ok))
+		Expect(attribute(root, "workflow.id")).Should(Equal(data.ID()))
+
+		for _, phase := range []string{"begin", "work", "beforeCommit", "commit", "finish"} {
+			span, ok := byName["workflow order "+phase]
+			Expect(ok).Should(BeTrue(), phase)
+			Expect(span.Parent.SpanID()).Should(Equal(root.SpanContext.SpanID()))
+		}
+		Expect(byName).ShouldNot(HaveKey("workflow order rollback"))
+
+		Expect(handlerSpan.IsValid()).Should(BeTrue())
+		Expect(handlerSpan.TraceID()).Should(Equal(root.SpanContext.TraceID()))
+		Expect(handlerSpan.SpanID()).ShouldNot(Equal(root.SpanContext.SpanID()))
+	})
+
+	It("records the failure of a handler", func() {
+		_, err := order.Run(context.Background(), func(ctx context.Context, data *workflow.WorkData) error {
+			return errors.New("work")
+		})
+		Expect(err).ShouldNot(BeNil())
+
+		byName := spans()
+		Expect(byName["workflow order"].Status.Code).Should(Equal(codes.Error))
+		Expect(byName["workflow order work"].Status.Code).Should(Equal(codes.Error))
+		Expect(byName).Should(HaveKey("workflow order rollback"))
+		Expect(eventNames(byName["workflow order"])).Should(ContainElement("rollback"))
+	})
+
+	It("records aborts as events", func() {
+		_, err := order.Run(context.Background(), func(ctx context.Context, data *workflow.WorkData) error {
+			data.AbortWithReason(errors.New("out of stock"))
+			return nil
+		})
+		Expect(err).Should(BeNil())
+
+		root := spans()["workflow order"]
+		Expect(root.Status.Code).Should(Equal(codes.Error))
+		Expect(root.Status.Description).Should(Equal("aborted"))
+		Expect(eventNames(root)).Should(ContainElement("abort"))
+	})
+
+	It("records panics as events of the handler span", func() {
+		order.Run(context.Background(), func(ctx context.Context, data *workflow.WorkData) error {
+			panic("boom")
+		})
+
+		var panicked []string
+		for _, span := range exporter.GetSpans() {
+			for _, name := range eventNames(span) {
+				if name == "panic" {
+					panicked = append(panicked, attribute(span, "workflow.handler.name"))
+				}
+			}
+		}
+		Expect(panicked).Should(HaveLen(1))
+		Expect(panicked[0]).ShouldNot(BeEmpty())
+		Expect(spans()["workflow order"].Status.Code).Should(Equal(codes.Error))
+	})
+})
+
+func attribute(span tracetest.SpanStub, key string) string {
+	for _, attr := range span.Attributes {
+		if string(attr.Key) == key {
+			return attr.Value.AsString()
+		}
+	}
+	return ""
+}
+
+func eventNames(span tracetest.SpanStub) []string {
+	var names []string
+	for _, event := range span.Events {
+		names = append(names, event.Name)
+	}
+	return names
+}
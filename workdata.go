@@ -2,74 +2,228 @@ package workflow
 
 import (
 	"context"
-	"fmt"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 type WorkData struct {
+	id               string
+	idGenerator      func() string
+	parentID         string
+	labels           map[string]string
+	startedAt        time.Time
+	endedAt          time.Time
+	name             string
+	phase            Phase
+	store            Store
+	durableKeys      []DurableKey
+	observers        observers
 	workBegin        *funcs
 	workBeforeCommit *funcs
 	workCommit       *funcs
 	workFinish       *funcs
 	workRollback     *funcs
 	currentState     *funcs
-	ctx              map[string]interface{}
-	ctxLocker        sync.RWMutex
+	ctx              map[interface{}]interface{}
+	ctxLocker        *sync.RWMutex
+	parent           *WorkData
+	locals           map[interface{}]interface{}
 	isAborted        bool
-	Logger           *logrus.Entry
+	abortReason      error
+	done             chan struct{}
+	stateLocker      sync.Mutex
+	result           Result
+	timeout          time.Duration
+	phaseTimeouts    map[Phase]time.Duration
+	phaseRetries     map[Phase]RetryPolicy
+	runRetry         *RetryPolicy
+	attempt          int
+	attempts         map[string]int
+	attemptsLocker   sync.Mutex
+	logLevels        LogLevels
+	Logger           Logger
+
+	compensations       []compensation
+	subs                []*WorkData
+	compensationsLocker sync.Mutex
+	steps               []StepRecord
+	stepsLocker         sync.Mutex
+	resources           []Resource
+	resourceStates      map[string]resourceState
+	resourceErrors      map[string]error
+	resourcesCommitting bool
+	resourcesLocker     sync.Mutex
 }
 
 func NewWorkData() *WorkData {
 	d := &WorkData{
-		workBegin:        NewFuncs(),
-		workBeforeCommit: NewFuncs(),
-		workCommit:       NewFuncs(),
-		workFinish:       NewFuncs(),
-		workRollback:     NewFuncs(),
-		ctx:              make(map[string]interface{}),
-		Logger:           logrus.StandardLogger().WithField("from", "workflow"),
+		id:               newID(),
+		idGenerator:      newID,
+		labels:           make(map[string]string),
+		workBegin:        newPhaseFuncs(PhaseBegin),
+		workBeforeCommit: newPhaseFuncs(PhaseBeforeCommit),
+		workCommit:       newPhaseFuncs(PhaseCommit),
+		workFinish:       newPhaseFuncs(PhaseFinish),
+		workRollback:     newPhaseFuncs(PhaseRollback),
+		ctx:              make(map[interface{}]interface{}),
+		ctxLocker:        &sync.RWMutex{},
+		locals:           make(map[interface{}]interface{}),
+		done:             make(chan struct{}),
+		phaseTimeouts:    make(map[Phase]time.Duration),
+		phaseRetries:     make(map[Phase]RetryPolicy),
+		attempt:          1,
+		attempts:         make(map[string]int),
+		resourceStates:   make(map[string]resourceState),
+		resourceErrors:   make(map[string]error),
+		observers:        observers{logObserver{}},
+		logLevels:        defaultLogLevels,
+		Logger:           Logrus(logrus.StandardLogger().WithField("from", "workflow")),
 	}
 	d.ResetProgress()
 	return d
 }
 
+// clone returns a new WorkData sharing the handlers and configuration
+// registered on d by options.
+func (d *WorkData) clone() *WorkData {
+	c := NewWorkData()
+	c.id = d.idGenerator()
+	c.idGenerator = d.idGenerator
+	c.parentID = d.parentID
+	c.labels = d.labels
+	c.name = d.name
+	c.workBegin = d.workBegin.clone()
+	c.workBeforeCommit = d.workBeforeCommit.clone()
+	c.workCommit = d.workCommit.clone()
+	c.workFinish = d.workFinish.clone()
+	c.workRollback = d.workRollback.clone()
+	c.timeout = d.timeout
+	c.phaseTimeouts = d.phaseTimeouts
+	c.phaseRetries = d.phaseRetries
+	c.runRetry = d.runRetry
+	c.store = d.store
+	c.durableKeys = d.durableKeys
+	c.observers = d.observers
+	c.logLevels = d.logLevels
+	c.resources = d.resources
+	c.Logger = d.Logger
+	return c
+}
+
+func (d *WorkData) validate() error {
+	for _, fs := range []*funcs{d.workBegin, d.workBeforeCommit, d.workCommit, d.workRollback, d.workFinish} {
+		if err := fs.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *WorkData) Parent() *WorkData {
+	return d.parent
+}
+
+func (d *WorkData) Name() string {
+	return d.name
+}
+
 func (d *WorkData) Get(key string) (interface{}, bool) {
-	d.ctxLocker.RLock()
-	defer d.ctxLocker.RUnlock()
-	data, ok := d.ctx[key]
-	return data, ok
+	return d.get(key)
 }
 
 func (d *WorkData) MustGet(key string) interface{} {
 	if data, ok := d.Get(key); !ok {
-		panic(fmt.Sprintf("key: %v is not exists", key))
+		panic((&KeyNotFoundError{Key: key}).Error())
 	} else {
 		return data
 	}
 }
 
 func (d *WorkData) Set(key string, value interface{}) {
+	d.set(key, value)
+}
+
+func (d *WorkData) get(key interface{}) (interface{}, bool) {
+	if value, ok := d.getLocal(key); ok {
+		return value, true
+	}
+
+	d.ctxLocker.RLock()
+	defer d.ctxLocker.RUnlock()
+	data, ok := d.ctx[key]
+	return data, ok
+}
+
+func (d *WorkData) set(key interface{}, value interface{}) {
 	d.ctxLocker.Lock()
 	defer d.ctxLocker.Unlock()
 	d.ctx[key] = value
 }
 
+// local values belong to a single run and are not shared with sub workflows.
+func (d *WorkData) getLocal(key interface{}) (interface{}, bool) {
+	d.stateLocker.Lock()
+	defer d.stateLocker.Unlock()
+	value, ok := d.locals[key]
+	return value, ok
+}
+
+func (d *WorkData) setLocal(key interface{}, value interface{}) {
+	d.stateLocker.Lock()
+	defer d.stateLocker.Unlock()
+	d.locals[key] = value
+}
+
+// setOwned sets a value owned by the run, e.g. its transaction. A sub workflow
+// keeps it local, so it hides the value of its parent without replacing it.
+func (d *WorkData) setOwned(key interface{}, value interface{}) {
+	if d.parent != nil {
+		d.setLocal(key, value)
+		return
+	}
+	d.set(key, value)
+}
+
 func (d *WorkData) Next(ctx context.Context) error {
-	if d.currentState == nil {
+	state := d.state()
+	if state == nil {
 		return nil
 	}
 
-	defer func() {
-		d.currentState = nil
-	}()
+	defer d.setState(nil)
+
+	return state.next(ctx, d)
+}
+
+func (d *WorkData) state() *funcs {
+	d.stateLocker.Lock()
+	defer d.stateLocker.Unlock()
+	return d.currentState
+}
 
-	return d.currentState.next(ctx, d)
+func (d *WorkData) setState(state *funcs) {
+	d.stateLocker.Lock()
+	defer d.stateLocker.Unlock()
+	d.currentState = state
 }
 
 func (d *WorkData) Abort() {
-	d.isAborted = true
+	d.AbortWithReason(nil)
+}
+
+func (d *WorkData) AbortWithReason(reason error) {
+	d.stateLocker.Lock()
+	defer d.stateLocker.Unlock()
+
+	if d.abortReason == nil {
+		d.abortReason = reason
+	}
+	if !d.isAborted {
+		d.isAborted = true
+		close(d.done)
+	}
 	if d.currentState == nil {
 		return
 	}
@@ -78,9 +232,42 @@ func (d *WorkData) Abort() {
 }
 
 func (d *WorkData) IsAborted() bool {
+	d.stateLocker.Lock()
+	defer d.stateLocker.Unlock()
 	return (d.currentState != nil && d.currentState.isAborted()) || d.isAborted
 }
 
+// Done returns a channel that is closed when the workflow is aborted.
+func (d *WorkData) Done() <-chan struct{} {
+	return d.done
+}
+
+func (d *WorkData) phaseFuncs(phase Phase) *funcs {
+	switch phase {
+	case PhaseBegin:
+		return d.workBegin
+	case PhaseBeforeCommit:
+		return d.workBeforeCommit
+	case PhaseCommit:
+		return d.workCommit
+	case PhaseRollback:
+		return d.workRollback
+	case PhaseFinish:
+		return d.workFinish
+	default:
+		return nil
+	}
+}
+
+// Progress returns how many handlers of the phase have completed.
+func (d *WorkData) Progress(phase Phase) int {
+	fs := d.phaseFuncs(phase)
+	if fs == nil {
+		return 0
+	}
+	return fs.completedCount()
+}
+
 func (d *WorkData) ResetProgress() {
 	d.workBegin.reset()
 	d.workBeforeCommit.reset()
@@ -90,26 +277,36 @@ func (d *WorkData) ResetProgress() {
 }
 
 func (d *WorkData) Begin(ctx context.Context) error {
-	d.currentState = d.workBegin
+	d.setState(d.workBegin)
 	return d.Next(ctx)
 }
 
 func (d *WorkData) BeforeCommit(ctx context.Context) error {
-	d.currentState = d.workBeforeCommit
+	d.setState(d.workBeforeCommit)
 	return d.Next(ctx)
 }
 
 func (d *WorkData) Commit(ctx context.Context) error {
-	d.currentState = d.workCommit
+	d.setState(d.workCommit)
 	return d.Next(ctx)
 }
 
 func (d *WorkData) Rollback(ctx context.Context) error {
-	d.currentState = d.workRollback
-	return d.Next(ctx)
+	if errs := d.rollback(ctx); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func (d *WorkData) rollback(ctx context.Context) []error {
+	errs := d.compensate(ctx)
+
+	d.setState(d.workRollback)
+	d.Next(ctx)
+	return append(errs, d.workRollback.failures()...)
 }
 
 func (d *WorkData) Finish(ctx context.Context) error {
-	d.currentState = d.workFinish
+	d.setState(d.workFinish)
 	return d.Next(ctx)
 }
@@ -2,7 +2,9 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 )
 
 func StartWorkFlow(f Event, opts ...Options) (*WorkData, error) {
@@ -10,53 +12,246 @@ func StartWorkFlow(f Event, opts ...Options) (*WorkData, error) {
 }
 
 func StartWorkFlowContext(ctx context.Context, f Event, opts ...Options) (data *WorkData, err error) {
+	return newWorkflow("", opts).Run(ctx, f)
+}
+
+// Workflow is an immutable workflow definition which can be run many times
+// concurrently, every run getting a fresh WorkData.
+type Workflow struct {
+	name     string
+	template *WorkData
+}
+
+func Define(name string, opts ...Options) (*Workflow, error) {
+	w := newWorkflow(name, opts)
+	if err := w.template.validate(); err != nil {
+		return nil, fmt.Errorf("define workflow %q: %w", name, err)
+	}
+	return w, nil
+}
+
+func MustDefine(name string, opts ...Options) *Workflow {
+	w, err := Define(name, opts...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return w
+}
+
+func newWorkflow(name string, opts []Options) *Workflow {
+	template := NewWorkData()
+	template.name = name
+	for _, opt := range opts {
+		opt.Apply(template)
+	}
+	if name != "" {
+		template.Logger = template.Logger.WithField("workflow", name)
+	}
+	return &Workflow{name: name, template: template}
+}
+
+func (w *Workflow) Name() string {
+	return w.name
+}
+
+func (w *Workflow) Run(ctx context.Context, f Event) (data *WorkData, err error) {
+	return w.run(ctx, f, nil)
+}
+
+func (w *Workflow) run(ctx context.Context, f Event, parent *WorkData) (data *WorkData, err error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	data = NewWorkData()
-	for _, opt := range opts {
-		opt.Apply(data)
+
+	for attempt := 1; ; attempt++ {
+		data = w.template.clone()
+		data.attempt = attempt
+		if parent != nil {
+			data.attach(parent)
+		}
+
+		err = run(ctx, data, f)
+		if pe := data.persist(detach(ctx), data.phase, data.Progress(data.phase)); pe != nil {
+			data.Logger.WithError(pe).Error("persist workflow state")
+		}
+
+		if !data.Result().Retry {
+			return
+		}
+
+		data.Logger.WithError(err).WithField("attempt", attempt).Warn("retrying workflow")
+		if !data.runRetry.wait(ctx, attempt) {
+			return
+		}
+	}
+}
+
+func restartable(outcome Outcome) bool {
+	return outcome == OutcomeFailed || outcome == OutcomeRolledBack
+}
+
+func run(ctx context.Context, data *WorkData, f Event) (err error) {
+	ctx = data.annotate(ctx)
+	defer func() {
+		data.result.Retry = data.retries(ctx)
+		data.finalize(ctx)
+	}()
+
+	if data.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, data.timeout)
+		defer cancel()
 	}
 
-	err = data.Begin(ctx)
+	err = runPhase(ctx, data, PhaseBegin, data.Begin)
 	if err != nil || data.IsAborted() {
+		// resources opened by the completed begin handlers are released
+		// through their compensations, the rollback handlers do not run.
+		err = abandon(ctx, data, PhaseBegin, err, OutcomeFailed, data.compensate)
 		return
 	}
 
+	phase := PhaseWork
 	defer func() {
-		if e := recover(); e != nil {
-			err = fmt.Errorf("%v", e)
-		}
-
 		if err != nil || data.IsAborted() {
-			re := data.Rollback(ctx)
-			if re != nil {
-				err = re
-				return
-			}
+			err = abandon(ctx, data, phase, err, OutcomeRolledBack, data.rollback)
 			return
 		}
 
-		if data.IsAborted() {
-			return
+		// a sub workflow is finished with its parent.
+		if data.parent == nil {
+			err = finish(ctx, data)
 		}
-		data.Finish(ctx)
+		err = data.withInDoubt(err)
+		data.setResult(OutcomeCommitted, err)
 	}()
 
-	err = f(ctx, data)
+	err = runPhase(ctx, data, PhaseWork, func(ctx context.Context) error {
+		if err := ctx.Err(); err != nil {
+			data.AbortWithReason(err)
+			return nil
+		}
+		info := HandlerInfo{Phase: PhaseWork, Name: handlerName(f)}
+		if err := data.observers.handle(ctx, data, info, data.wrapRetry(PhaseWork, f)); err != nil {
+			return &HandlerError{Phase: PhaseWork, Handler: info.Name, Err: err}
+		}
+		if err := ctx.Err(); err != nil {
+			data.AbortWithReason(err)
+		}
+		return nil
+	})
 	if err != nil || data.IsAborted() {
 		return
 	}
 
-	err = data.BeforeCommit(ctx)
+	phase = PhaseBeforeCommit
+	err = runPhase(ctx, data, PhaseBeforeCommit, data.BeforeCommit)
 	if err != nil || data.IsAborted() {
 		return
 	}
 
-	err = data.Commit(ctx)
+	phase = PhaseCommit
+	err = runPhase(ctx, data, PhaseCommit, data.Commit)
 	if err != nil || data.IsAborted() {
 		return
 	}
 
 	return
 }
+
+// finish runs the finish phase of committed work and of its committed sub
+// workflows, even if the caller is gone.
+func finish(ctx context.Context, data *WorkData) error {
+	ctx = detach(ctx)
+
+	var errs []error
+	if err := runPhase(ctx, data, PhaseFinish, data.Finish); err != nil {
+		errs = data.workFinish.failures()
+		if len(errs) == 0 {
+			errs = []error{err}
+		}
+	}
+	for _, sub := range data.takeSubs() {
+		var werr *WorkflowError
+		if errors.As(finish(ctx, sub), &werr) {
+			errs = append(errs, werr.FinishErrors...)
+		}
+	}
+
+	if len(errs) > 0 {
+		return &WorkflowError{Phase: PhaseFinish, Index: -1, FinishErrors: errs}
+	}
+	return nil
+}
+
+func runPhase(ctx context.Context, data *WorkData, phase Phase, run func(context.Context) error) error {
+	ctx, cancel := data.phaseContext(ctx, phase)
+	defer cancel()
+
+	data.phase = phase
+	if err := data.persist(ctx, phase, data.Progress(phase)); err != nil {
+		if phase == PhaseFinish {
+			data.Logger.WithError(err).Error("persist workflow state")
+		} else {
+			return fmt.Errorf("persist workflow state: %w", err)
+		}
+	}
+
+	ctx = data.observers.phaseStart(ctx, data, phase)
+	start := time.Now()
+	err := run(ctx)
+	data.observers.phaseEnd(ctx, data, phase, err, time.Since(start))
+	return err
+}
+
+func abandon(ctx context.Context, data *WorkData, phase Phase, err error, failed Outcome, rollback func(context.Context) []error) error {
+	ctx, cancel := data.phaseContext(detach(ctx), PhaseRollback)
+	defer cancel()
+
+	outcome := OutcomeAborted
+	var werr *WorkflowError
+	if err != nil {
+		werr = newWorkflowError(phase, err)
+		outcome = failedOutcome(err, failed)
+	} else {
+		data.observers.abort(ctx, data, data.AbortReason())
+	}
+
+	data.phase = PhaseRollback
+	if pe := data.persist(ctx, PhaseRollback, data.Progress(PhaseRollback)); pe != nil {
+		data.Logger.WithError(pe).Error("persist workflow state")
+	}
+
+	ctx = data.observers.phaseStart(ctx, data, PhaseRollback)
+	start := time.Now()
+	errs := rollback(ctx)
+	var rollbackErr error
+	if len(errs) > 0 {
+		rollbackErr = errs[0]
+	}
+	data.observers.phaseEnd(ctx, data, PhaseRollback, rollbackErr, time.Since(start))
+	data.observers.rollback(ctx, data, errs)
+
+	if len(errs) > 0 {
+		if werr == nil {
+			werr = &WorkflowError{Phase: PhaseRollback, Index: -1, Cause: data.AbortReason()}
+		}
+		werr.RollbackErrors = errs
+		outcome = OutcomeRollbackFailed
+	}
+
+	if werr == nil {
+		data.setResult(outcome, nil)
+		return nil
+	}
+	data.setResult(outcome, werr)
+	return werr
+}
+
+func failedOutcome(err error, outcome Outcome) Outcome {
+	var perr *PanicError
+	if errors.As(err, &perr) {
+		return OutcomePanicked
+	}
+	return outcome
+}
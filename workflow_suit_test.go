@@ -2,11 +2,15 @@ package workflow_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/chein-huang/workflow"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"gorm.io/driver/sqlite"
+	gormV2 "gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 func TestWorkFlow(t *testing.T) {
@@ -56,3 +60,15 @@ func (s *TestStruct) WorkFinish(ctx context.Context, data *workflow.WorkData) er
 	}
 	return nil
 }
+
+var testDBSeq int
+
+func openTestDB() *gormV2.DB {
+	testDBSeq++
+	dsn := fmt.Sprintf("file:workflow%d?mode=memory&cache=shared", testDBSeq)
+	db, err := gormV2.Open(sqlite.Open(dsn), &gormV2.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	Expect(err).Should(BeNil())
+	return db
+}
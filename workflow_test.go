@@ -2,6 +2,7 @@ package workflow_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/chein-huang/workflow"
@@ -323,7 +324,7 @@ var _ = Describe("Workflow", func() {
 			Expect(data.MustGet("work")).To(Equal(2))
 			Expect(data.MustGet("beforeCommit")).To(Equal(3))
 			Expect(data.MustGet("commit")).To(Equal(4))
-			Expect(data.MustGet("rollback")).To(Equal(5))
+			Expect(data.MustGet("rollback")).To(Equal(6))
 			Expect(data.Get("finish")).Should(BeNil())
 		})
 
@@ -349,12 +350,15 @@ var _ = Describe("Workflow", func() {
 				workflow.WithFinish(finishFailed),
 				workflow.WithInterface(&s),
 			)
-			Expect(err).Should(BeNil())
+			var werr *workflow.WorkflowError
+			Expect(errors.As(err, &werr)).To(BeTrue())
+			Expect(werr.Phase).To(Equal(workflow.PhaseFinish))
+			Expect(werr.FinishErrors).To(HaveLen(1))
 			Expect(data.MustGet("begin")).To(Equal(1))
 			Expect(data.MustGet("work")).To(Equal(2))
 			Expect(data.MustGet("beforeCommit")).To(Equal(3))
 			Expect(data.MustGet("commit")).To(Equal(4))
-			Expect(data.MustGet("finish")).To(Equal(5))
+			Expect(data.MustGet("finish")).To(Equal(6))
 			Expect(data.Get("rollback")).Should(BeNil())
 		})
 
@@ -364,7 +368,10 @@ var _ = Describe("Workflow", func() {
 				workflow.WithInterface(&s),
 				workflow.WithFinish(finishFailed),
 			)
-			Expect(err).Should(BeNil())
+			var werr *workflow.WorkflowError
+			Expect(errors.As(err, &werr)).To(BeTrue())
+			Expect(werr.Phase).To(Equal(workflow.PhaseFinish))
+			Expect(werr.FinishErrors).To(HaveLen(1))
 			Expect(data.MustGet("begin")).To(Equal(1))
 			Expect(data.MustGet("work")).To(Equal(2))
 			Expect(data.MustGet("beforeCommit")).To(Equal(3))
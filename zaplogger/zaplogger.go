@@ -0,0 +1,31 @@
+// Package zaplogger adapts a zap logger to workflow.Logger.
+package zaplogger
+
+import (
+	"github.com/chein-huang/workflow"
+	"go.uber.org/zap"
+)
+
+type logger struct {
+	logger *zap.Logger
+}
+
+// New adapts l to workflow.Logger:
+//
+//	workflow.StartWorkFlow(work, workflow.WithLog(zaplogger.New(l)))
+func New(l *zap.Logger) workflow.Logger {
+	return logger{logger: l}
+}
+
+func (l logger) WithField(key string, value interface{}) workflow.Logger {
+	return logger{logger: l.logger.With(zap.Any(key, value))}
+}
+
+func (l logger) WithError(err error) workflow.Logger {
+	return logger{logger: l.logger.With(zap.Error(err))}
+}
+
+func (l logger) Debug(msg string) { l.logger.Debug(msg) }
+func (l logger) Info(msg string)  { l.logger.Info(msg) }
+func (l logger) Warn(msg string)  { l.logger.Warn(msg) }
+func (l logger) Error(msg string) { l.logger.Error(msg) }
@@ -0,0 +1,13 @@
+package zaplogger_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestZapLogger(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ZapLogger Suite")
+}
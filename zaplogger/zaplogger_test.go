@@ -0,0 +1,39 @@
+package zaplogger_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chein-huang/workflow"
+	"github.com/chein-huang/workflow/zaplogger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var _ = Describe("Logger", func() {
+	It("logs through zap", func() {
+		core, logs := observer.New(zapcore.DebugLevel)
+
+		_, err := workflow.StartWorkFlow(
+			func(ctx context.Context, data *workflow.WorkData) error {
+				data.Logger.WithField("order", 42).Info("working")
+				return errors.New("work")
+			},
+			workflow.WithLog(zaplogger.New(zap.New(core))),
+		)
+		Expect(err).ShouldNot(BeNil())
+
+		working := logs.FilterMessage("working").All()
+		Expect(working).Should(HaveLen(1))
+		Expect(working[0].ContextMap()).Should(HaveKeyWithValue("order", int64(42)))
+		Expect(working[0].ContextMap()).Should(HaveKeyWithValue("from", "workflow"))
+
+		failed := logs.FilterMessage("handler failed").All()
+		Expect(failed).Should(HaveLen(1))
+		Expect(failed[0].Level).Should(Equal(zapcore.ErrorLevel))
+		Expect(failed[0].ContextMap()).Should(HaveKeyWithValue("error", "work"))
+	})
+})